@@ -11,12 +11,38 @@
 
 set -euo pipefail
 
+# logical_name turns a project name into a valid Rocq logical path
+# component, like LogicalName in shared/workspace: "rocq-workspace" becomes
+# "RocqWorkspace". Non-ASCII letters are kept in a UTF-8 locale only, and
+# not capitalized. Runs with the bash 3.2 of macOS.
+logical_name() {
+  local name="$1" logical="" upper=1 c i
+  for (( i = 0; i < ${#name}; i++ )); do
+    c="${name:i:1}"
+    if [[ "$c" == [[:alnum:]] ]]; then
+      if (( upper )); then
+        c="$(printf %s "$c" | tr '[:lower:]' '[:upper:]')"
+        upper=0
+      fi
+      logical+="$c"
+    else
+      upper=1
+    fi
+  done
+  if [[ -z "$logical" ]]; then
+    logical="Project"
+  elif [[ "${logical:0:1}" != [[:alpha:]] ]]; then
+    logical="P$logical"
+  fi
+  printf '%s\n' "$logical"
+}
+
 ensure_workspace() {
   mkdir -p -- "$WORKSPACE_DIR/.vscode"
 
   # main.v
-  if [[ -f "$SCRIPT_DIR/templates/main.v" ]]; then
-    cp -n -- "$SCRIPT_DIR/templates/main.v" "$WORKSPACE_DIR/main.v" || true
+  if [[ -f "$SCRIPT_DIR/templates/minimal/main.v" ]]; then
+    cp -n -- "$SCRIPT_DIR/templates/minimal/main.v" "$WORKSPACE_DIR/main.v" || true
   else
     [[ -f "$WORKSPACE_DIR/main.v" ]] || cat > "$WORKSPACE_DIR/main.v" <<'EOF'
 Theorem hello : 0 = 0.
@@ -25,8 +51,8 @@ EOF
   fi
 
   # test.v
-  if [[ -f "$SCRIPT_DIR/templates/test.v" ]]; then
-    cp -n -- "$SCRIPT_DIR/templates/test.v" "$WORKSPACE_DIR/test.v" || true
+  if [[ -f "$SCRIPT_DIR/templates/minimal/test.v" ]]; then
+    cp -n -- "$SCRIPT_DIR/templates/minimal/test.v" "$WORKSPACE_DIR/test.v" || true
   else
     [[ -f "$WORKSPACE_DIR/test.v" ]] || cat > "$WORKSPACE_DIR/test.v" <<'EOF'
 Theorem t : 0 = 0.
@@ -34,14 +60,19 @@ Proof. reflexivity. Qed.
 EOF
   fi
 
-  # _RocqProject (preferred)
-  if [[ -f "$SCRIPT_DIR/templates/_RocqProject" ]]; then
-    cp -n -- "$SCRIPT_DIR/templates/_RocqProject" "$WORKSPACE_DIR/_RocqProject" || true
-  else
-    [[ -f "$WORKSPACE_DIR/_RocqProject" ]] || cat > "$WORKSPACE_DIR/_RocqProject" <<'EOF'
-# Minimal Rocq project file
--Q . ""
-EOF
+  # _RocqProject (preferred), rendered like the Go installers do
+  if [[ ! -f "$WORKSPACE_DIR/_RocqProject" ]]; then
+    local project_name logical_prefix tmpl
+    project_name="$(basename -- "$WORKSPACE_DIR")"
+    logical_prefix="$(logical_name "$project_name")"
+    if [[ -f "$SCRIPT_DIR/templates/minimal/_RocqProject.tmpl" ]]; then
+      tmpl="$(<"$SCRIPT_DIR/templates/minimal/_RocqProject.tmpl")"
+    else
+      tmpl=$'# Rocq project file for {{.ProjectName}}\n-Q . {{.LogicalPrefix}}'
+    fi
+    tmpl="${tmpl//"{{.ProjectName}}"/"$project_name"}"
+    tmpl="${tmpl//"{{.LogicalPrefix}}"/"$logical_prefix"}"
+    printf '%s\n' "$tmpl" > "$WORKSPACE_DIR/_RocqProject"
   fi
 
   # Activate scripts (Linux only)
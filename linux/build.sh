@@ -13,9 +13,8 @@ cd "$SCRIPT_DIR"
 
 echo "==> Syncing embedded assets..."
 cp -f ../manifest/latest.json embedded/manifest/latest.json
-cp -f ../templates/test.v embedded/templates/test.v
-cp -f ../templates/main.v embedded/templates/main.v
-cp -f ../templates/_RocqProject embedded/templates/_RocqProject
+rm -rf embedded/templates
+cp -R ../templates embedded/templates
 
 echo "==> Building rocq-bootstrap (Linux amd64)..."
 CGO_ENABLED=1 \
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	rootfs "github.com/justme0606/rocq-bootstrap/linux"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/installer"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/manifest"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/workspace"
+	"github.com/justme0606/rocq-bootstrap/shared/settings"
+)
+
+// devContainer writes the dev container recreating the switch of the
+// embedded manifest into the workspace dir, or the remembered one if empty.
+func devContainer(dir string) error {
+	m, err := manifest.Load(rootfs.EmbeddedManifest, "embedded/manifest/latest.json")
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		if dir, err = settings.Load().Workspace(); err != nil {
+			return err
+		}
+	}
+	if dir, err = workspace.ResolveDir(dir); err != nil {
+		return err
+	}
+	dc := installer.DevContainer(m)
+	if err := workspace.WriteDevContainer(dir, dc); err != nil {
+		return err
+	}
+	fmt.Printf("Dev container for %s written to %s\n", dc.SwitchName, filepath.Join(dir, ".devcontainer"))
+	return nil
+}
@@ -5,12 +5,16 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/justme0606/rocq-bootstrap/shared/settings"
 	"github.com/justme0606/rocq-bootstrap/shared/startup"
 
 	rootfs "github.com/justme0606/rocq-bootstrap/linux"
 	"github.com/justme0606/rocq-bootstrap/linux/internal/gui"
 	"github.com/justme0606/rocq-bootstrap/linux/internal/manifest"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/vscode"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/workspace"
 )
 
 var Version = "dev"
@@ -30,13 +34,109 @@ Keywords=Rocq;Coq;proof;assistant;opam;
 )
 
 func main() {
-	showLog := false
-	for _, arg := range os.Args[1:] {
-		if arg == "--log" {
-			showLog = true
+	opts := &gui.Options{}
+	args := os.Args[1:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--log":
+			opts.ShowLog = true
+		case args[i] == "--local-switch":
+			opts.LocalSwitch = true
+		case args[i] == "--opam-root" && i+1 < len(args):
+			i++
+			opts.OpamRoot = args[i]
+		case strings.HasPrefix(args[i], "--opam-root="):
+			opts.OpamRoot = strings.TrimPrefix(args[i], "--opam-root=")
+		case args[i] == "--template" && i+1 < len(args):
+			i++
+			opts.Template = args[i]
+		case strings.HasPrefix(args[i], "--template="):
+			opts.Template = strings.TrimPrefix(args[i], "--template=")
+		case args[i] == "--workspace" && i+1 < len(args):
+			i++
+			opts.WorkspaceDir = args[i]
+		case strings.HasPrefix(args[i], "--workspace="):
+			opts.WorkspaceDir = strings.TrimPrefix(args[i], "--workspace=")
+		case args[i] == "--code-workspace":
+			opts.CodeWorkspace = true
+		case args[i] == "--git":
+			opts.Git = true
+		case args[i] == "--direnv":
+			opts.Direnv = true
+		case args[i] == "--company-coq":
+			opts.CompanyCoq = true
+		case args[i] == "--editor" && i+1 < len(args):
+			i++
+			opts.Editor = args[i]
+		case strings.HasPrefix(args[i], "--editor="):
+			opts.Editor = strings.TrimPrefix(args[i], "--editor=")
+		case args[i] == "--code" && i+1 < len(args):
+			i++
+			opts.CodeEditor = args[i]
+		case strings.HasPrefix(args[i], "--code="):
+			opts.CodeEditor = strings.TrimPrefix(args[i], "--code=")
+		case args[i] == "--vsix" && i+1 < len(args):
+			i++
+			opts.VSIX = args[i]
+		case strings.HasPrefix(args[i], "--vsix="):
+			opts.VSIX = strings.TrimPrefix(args[i], "--vsix=")
+		case args[i] == "--settings-scope" && i+1 < len(args):
+			i++
+			opts.SettingsScope = args[i]
+		case strings.HasPrefix(args[i], "--settings-scope="):
+			opts.SettingsScope = strings.TrimPrefix(args[i], "--settings-scope=")
+		case args[i] == "--profile" && i+1 < len(args):
+			i++
+			opts.Profile = args[i]
+		case strings.HasPrefix(args[i], "--profile="):
+			opts.Profile = strings.TrimPrefix(args[i], "--profile=")
+		case args[i] == "--uninstall-conflicting":
+			opts.UninstallConflicting = true
+		case args[i] == "--build" && i+1 < len(args):
+			i++
+			opts.Build = args[i]
+		case strings.HasPrefix(args[i], "--build="):
+			opts.Build = strings.TrimPrefix(args[i], "--build=")
 		}
 	}
 
+	if opts.Template != "" {
+		if _, err := workspace.LoadTemplate(rootfs.EmbeddedTemplates, opts.Template); err != nil {
+			fmt.Fprintf(os.Stderr, "%v (see --list-templates)\n", err)
+			os.Exit(2)
+		}
+	}
+	if opts.Build != "" && opts.Build != workspace.BuildMake && opts.Build != workspace.BuildDune {
+		fmt.Fprintf(os.Stderr, "unknown build system %q (use %s or %s)\n", opts.Build, workspace.BuildMake, workspace.BuildDune)
+		os.Exit(2)
+	}
+	if opts.Editor != "" && opts.Editor != workspace.EditorVSCode && opts.Editor != workspace.EditorEmacs && opts.Editor != workspace.EditorNeovim {
+		fmt.Fprintf(os.Stderr, "unknown editor %q (use %s, %s or %s)\n", opts.Editor, workspace.EditorVSCode, workspace.EditorEmacs, workspace.EditorNeovim)
+		os.Exit(2)
+	}
+	if opts.SettingsScope != "" && opts.SettingsScope != workspace.ScopeWorkspace && opts.SettingsScope != workspace.ScopeUser {
+		fmt.Fprintf(os.Stderr, "unknown settings scope %q (use %s or %s)\n", opts.SettingsScope, workspace.ScopeWorkspace, workspace.ScopeUser)
+		os.Exit(2)
+	}
+	if opts.CodeEditor != "" {
+		if _, err := vscode.SelectEditor(vscode.FindEditors(), opts.CodeEditor); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if opts.VSIX != "" {
+		// The editor CLI may resolve a relative path elsewhere.
+		path, err := filepath.Abs(opts.VSIX)
+		if err == nil {
+			_, err = os.Stat(path)
+		}
+		if err != nil || !strings.HasSuffix(path, ".vsix") {
+			fmt.Fprintf(os.Stderr, "%s is not a .vsix file\n", opts.VSIX)
+			os.Exit(2)
+		}
+		opts.VSIX = path
+	}
+
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "--install":
@@ -51,13 +151,97 @@ func main() {
 				os.Exit(1)
 			}
 			return
+		case "--restore-extensions":
+			if err := restoreExtensions(); err != nil {
+				fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "--reproduce":
+			if len(os.Args) < 3 {
+				fmt.Fprintln(os.Stderr, "Usage: rocq-bootstrap --reproduce LOCKFILE [--opam-root DIR] [--workspace DIR]")
+				os.Exit(2)
+			}
+			if err := reproduce(os.Args[2], opts); err != nil {
+				fmt.Fprintf(os.Stderr, "Reproduce failed: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "--devcontainer":
+			if err := devContainer(opts.WorkspaceDir); err != nil {
+				fmt.Fprintf(os.Stderr, "Dev container failed: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "--workspaces", "--relink", "--forget", "--forget-missing":
+			if err := workspacesCommand(os.Args[1:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case "--list-templates":
+			if err := listTemplates(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
 		case "--help", "-h":
-			fmt.Println("Usage: rocq-bootstrap [--install | --uninstall | --log | --help]")
+			fmt.Println("Usage: rocq-bootstrap [--install | --uninstall | --reproduce LOCKFILE | --devcontainer | --log | --opam-root DIR | --local-switch | --template NAME | --workspace DIR | --code-workspace | --editor vscode|emacs|neovim | --code CLI | --vsix FILE | --settings-scope workspace|user | --profile NAME | --uninstall-conflicting | --restore-extensions | --company-coq | --build make|dune | --git | --direnv | --list-templates | --workspaces | --relink DIR SWITCH | --forget DIR | --forget-missing | --help]")
 			fmt.Println()
 			fmt.Println("  (no args)     Launch the GUI installer")
 			fmt.Println("  --install     Install as desktop application (~/.local)")
-			fmt.Println("  --uninstall   Remove desktop application")
+			fmt.Println("  --uninstall   Remove desktop application and restore the extensions uninstalled")
+			fmt.Println("                with --uninstall-conflicting")
+			fmt.Println("  --reproduce LOCKFILE")
+			fmt.Println("                Recreate the environment recorded in a lock file (no GUI)")
+			fmt.Println("  --devcontainer")
+			fmt.Println("                Write a dev container recreating the switch into the workspace")
+			fmt.Println("                (the remembered one, or --workspace DIR)")
 			fmt.Println("  --log         Show the log panel in the GUI")
+			fmt.Println("  --opam-root DIR")
+			fmt.Println("                Install into a dedicated opam root instead of ~/.opam")
+			fmt.Println("  --local-switch")
+			fmt.Println("                Create a project-local switch in the workspace")
+			fmt.Println("  --template NAME")
+			fmt.Println("                Create the workspace from the given template")
+			fmt.Println("  --workspace DIR")
+			fmt.Println("                Create the workspace in DIR (remembered for the next runs)")
+			fmt.Println("  --code-workspace")
+			fmt.Println("                Also create a .code-workspace file and open VSCode with it")
+			fmt.Println("  --editor vscode|emacs|neovim")
+			fmt.Println("                Configure VSCode, Emacs with Proof General, or Neovim with the")
+			fmt.Println("                language server and Vim with Coqtail (default: VSCode, or")
+			fmt.Println("                Emacs if VSCode is not found)")
+			fmt.Println("  --code CLI    VSCode-family editor to use when several are installed, by its")
+			fmt.Println("                command line (code, code-insiders, codium, cursor, a Flatpak")
+			fmt.Println("                application ID or a path)")
+			fmt.Println("  --vsix FILE   Install the VSCode extension from a local .vsix (no Marketplace")
+			fmt.Println("                access needed)")
+			fmt.Println("  --settings-scope workspace|user")
+			fmt.Println("                Write the language server path into the workspace settings")
+			fmt.Println("                (default) or into the VSCode user settings, for every folder")
+			fmt.Println("  --profile NAME")
+			fmt.Println("                Install the extension in the VSCode profile NAME (created if")
+			fmt.Println("                needed) and open the workspace with it")
+			fmt.Println("  --uninstall-conflicting")
+			fmt.Println("                Uninstall the VSCoq or VSRocq extension conflicting with the")
+			fmt.Println("                one set up, instead of disabling it in the window opened now")
+			fmt.Println("                (VSCode does not keep that for the next windows)")
+			fmt.Println("  --restore-extensions")
+			fmt.Println("                Reinstall the extensions removed by --uninstall-conflicting")
+			fmt.Println("  --company-coq With Emacs, also set up company-coq")
+			fmt.Println("  --build make|dune")
+			fmt.Println("                Set up a build from _RocqProject and check that the workspace builds")
+			fmt.Println("  --git         Initialize the workspace as a git repository")
+			fmt.Println("  --direnv      Also write a .envrc loading the switch with direnv")
+			fmt.Println("  --list-templates")
+			fmt.Println("                List the available workspace templates")
+			fmt.Println("  --workspaces  List the registered workspaces and their switches")
+			fmt.Println("  --relink DIR SWITCH")
+			fmt.Println("                Bind the workspace DIR to another opam switch")
+			fmt.Println("  --forget DIR  Remove DIR from the registered workspaces")
+			fmt.Println("  --forget-missing")
+			fmt.Println("                Remove the registered workspaces that no longer exist")
 			fmt.Println("  --help        Show this help")
 			return
 		}
@@ -71,12 +255,24 @@ func main() {
 			m, err = manifest.Load(rootfs.EmbeddedManifest, "embedded/manifest/latest.json")
 			return err
 		},
-		RunGUI:          func() { gui.Run(m, rootfs.EmbeddedTemplates, rootfs.EmbeddedIcon, Version, showLog) },
+		RunGUI:          func() { gui.Run(m, rootfs.EmbeddedTemplates, rootfs.EmbeddedIcon, Version, opts) },
 		RocqVersion:     func() string { return m.RocqVersion },
 		PlatformRelease: func() string { return m.PlatformRelease },
 	})
 }
 
+// listTemplates prints the embedded workspace templates.
+func listTemplates() error {
+	list, err := workspace.ListTemplates(rootfs.EmbeddedTemplates)
+	if err != nil {
+		return err
+	}
+	for _, t := range list {
+		fmt.Printf("%-10s %s: %s\n", t.ID, t.Name, t.Description)
+	}
+	return nil
+}
+
 func installDesktop() error {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -145,10 +341,24 @@ func uninstallDesktop() error {
 		}
 	}
 
+	if err := restoreExtensions(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not restore extensions: %v\n", err)
+	}
+
 	fmt.Println("Rocq Bootstrap uninstalled.")
 	return nil
 }
 
+// restoreExtensions installs again the extensions uninstalled because they
+// conflicted with the one the installer set up.
+func restoreExtensions() error {
+	restored, err := settings.RestoreExtensions()
+	for _, r := range restored {
+		fmt.Printf("Restored: %s %s (%s)\n", r.ID, r.Version, r.Editor)
+	}
+	return err
+}
+
 func copyFile(src, dst string, perm os.FileMode) error {
 	in, err := os.Open(src)
 	if err != nil {
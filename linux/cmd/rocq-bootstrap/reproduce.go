@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	rootfs "github.com/justme0606/rocq-bootstrap/linux"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/gui"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/installer"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/lockfile"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/manifest"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/workspace"
+	"github.com/justme0606/rocq-bootstrap/shared/settings"
+)
+
+// reproduce recreates the environment described by a lock file without
+// launching the GUI, printing progress and any difference from the lock.
+func reproduce(path string, opts *gui.Options) error {
+	m, err := manifest.Load(rootfs.EmbeddedManifest, "embedded/manifest/latest.json")
+	if err != nil {
+		return err
+	}
+	lock, err := lockfile.Read(path)
+	if err != nil {
+		return err
+	}
+
+	workspaceDir := opts.WorkspaceDir
+	if workspaceDir == "" {
+		if workspaceDir, err = settings.Load().Workspace(); err != nil {
+			return err
+		}
+	}
+
+	logger, err := installer.NewLogger()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not create log file: %v\n", err)
+	}
+	if logger != nil {
+		defer logger.Close()
+	}
+
+	fmt.Printf("Reproducing %s (platform %s, Rocq %s)\n", path, lock.PlatformRelease, lock.RocqVersion)
+	lastStep := 0
+	result, err := installer.Run(&installer.Config{
+		Manifest:             m,
+		Templates:            rootfs.EmbeddedTemplates,
+		Template:             opts.Template,
+		WorkspaceDir:         workspaceDir,
+		CodeWorkspace:        opts.CodeWorkspace,
+		Editor:               opts.Editor,
+		CodeEditor:           opts.CodeEditor,
+		VSIX:                 opts.VSIX,
+		SettingsScope:        opts.SettingsScope,
+		Profile:              opts.Profile,
+		UninstallConflicting: opts.UninstallConflicting,
+		CompanyCoq:           opts.CompanyCoq,
+		Build:                opts.Build,
+		Git:                  opts.Git,
+		Direnv:               opts.Direnv,
+		OpamRoot:             opts.OpamRoot,
+		Lock:                 lock,
+		Logger:               logger,
+		OnStep: func(step int, label string, fraction float64) {
+			if step != lastStep || fraction >= 1.0 {
+				fmt.Printf("[step %d] %s\n", step, label)
+				lastStep = step
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Printf("Opam switch: %s\n", installer.Installation{Root: result.OpamRoot, Switch: result.SwitchName}.Label())
+	fmt.Printf("Workspace:   %s\n", result.WorkspaceDir)
+	if hint := workspace.SetupHint(result.Editor, result.WorkspaceDir); hint != "" {
+		fmt.Println(hint)
+	}
+	if len(result.Differences) == 0 {
+		fmt.Println("Environment reproduced exactly.")
+		return nil
+	}
+	fmt.Printf("%d item(s) could not be reproduced exactly:\n", len(result.Differences))
+	for _, d := range result.Differences {
+		fmt.Printf("  %s\n", d)
+	}
+	return nil
+}
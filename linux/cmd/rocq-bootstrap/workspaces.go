@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/justme0606/rocq-bootstrap/linux/internal/installer"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/workspace"
+	"github.com/justme0606/rocq-bootstrap/shared/registry"
+)
+
+// workspacesCommand runs the registry command in args[0]: --workspaces,
+// --relink DIR SWITCH, --forget DIR or --forget-missing.
+func workspacesCommand(args []string) error {
+	reg, err := registry.Load()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "--workspaces":
+		if len(reg.Workspaces) == 0 {
+			fmt.Println("No registered workspace.")
+		}
+		for _, e := range reg.Workspaces {
+			fmt.Printf("%s\n  -> %s\n", e.Dir, e.Environment())
+			if e.PlatformRelease != "" {
+				fmt.Printf("  platform %s, Rocq %s\n", e.PlatformRelease, e.RocqVersion)
+			}
+			if e.LanguageServer != "" {
+				fmt.Printf("  language server: %s\n", e.LanguageServer)
+			}
+			if e.Profile != "" {
+				fmt.Printf("  VSCode profile: %s\n", e.Profile)
+			}
+			if e.UserSettings != "" {
+				fmt.Printf("  language server set in the user settings: %s\n", e.UserSettings)
+			}
+			for _, p := range e.Problems() {
+				fmt.Printf("  warning: %s\n", p)
+			}
+		}
+		return nil
+
+	case "--relink":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: rocq-bootstrap --relink DIR SWITCH")
+		}
+		dir, err := workspace.ResolveDir(args[1])
+		if err != nil {
+			return err
+		}
+		e, err := installer.Relink(dir, args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s -> %s\n", e.Dir, e.Environment())
+		return nil
+
+	case "--forget":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: rocq-bootstrap --forget DIR")
+		}
+		dir, err := workspace.ResolveDir(args[1])
+		if err != nil {
+			return err
+		}
+		if !reg.Forget(dir) {
+			return fmt.Errorf("%s is not a registered workspace", dir)
+		}
+		fmt.Printf("Forgot %s\n", dir)
+		return reg.Save()
+
+	case "--forget-missing":
+		removed := reg.ForgetMissing()
+		for _, e := range removed {
+			fmt.Printf("Forgot %s\n", e.Dir)
+		}
+		if len(removed) == 0 {
+			return nil
+		}
+		return reg.Save()
+	}
+	return fmt.Errorf("unknown command %s", args[0])
+}
@@ -0,0 +1,10 @@
+(** * Exercise 1: propositional logic *)
+
+Theorem and_swap : forall P Q : Prop, P /\ Q -> Q /\ P.
+Proof.
+  (* Replace Admitted with your proof. *)
+Admitted.
+
+Theorem or_swap : forall P Q : Prop, P \/ Q -> Q \/ P.
+Proof.
+Admitted.
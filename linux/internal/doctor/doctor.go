@@ -1,24 +1,32 @@
 package doctor
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/justme0606/rocq-bootstrap/linux/internal/emacs"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/opam"
 	"github.com/justme0606/rocq-bootstrap/linux/internal/vscode"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/workspace"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/wsl"
+	"github.com/justme0606/rocq-bootstrap/shared/registry"
+	"github.com/justme0606/rocq-bootstrap/shared/settings"
 )
 
 // Run performs system diagnostics and reports findings via onLog callback.
 func Run(onLog func(string)) {
+	runner := &opam.Runner{Bin: opam.Locate()}
+
 	onLog("=== Opam ===")
-	opamFound := checkOpam(onLog)
+	opamFound := checkOpam(runner, onLog)
 
 	onLog("")
 	onLog("=== Rocq Platform Switches ===")
-	installFound := checkSwitches(onLog)
+	installFound := opamFound && checkSwitches(runner, onLog)
 
 	onLog("")
 	onLog("=== Binaries in PATH ===")
@@ -26,28 +34,38 @@ func Run(onLog func(string)) {
 
 	onLog("")
 	onLog("=== VSCode ===")
-	vsrocqFound, vscoqFound := checkVSCode(onLog)
+	extension := workspaceExtension()
+	found, conflictFound := checkVSCode(extension, onLog)
+
+	onLog("")
+	onLog("=== Emacs ===")
+	checkEmacs(onLog)
 
 	onLog("")
 	onLog("=== Workspace ===")
 	checkWorkspace(onLog)
 
+	onLog("")
+	onLog("=== Registered Workspaces ===")
+	checkRegisteredWorkspaces(runner, onLog)
+
 	onLog("")
 	onLog("=== Potential Issues ===")
-	checkIssues(onLog, opamFound, installFound, vsrocqFound, vscoqFound)
+	checkIssues(runner, onLog, opamFound, installFound, extension, found, conflictFound)
 }
 
-func checkOpam(onLog func(string)) bool {
-	path, err := exec.LookPath("opam")
-	if err != nil {
+func checkOpam(runner *opam.Runner, onLog func(string)) bool {
+	if runner.Bin == "" {
 		onLog("  \u26a0 opam not found in PATH")
 		return false
 	}
-	onLog(fmt.Sprintf("  \u2713 opam: %s", path))
+	if opam.IsManaged(runner.Bin) {
+		onLog(fmt.Sprintf("  \u2713 opam: %s (managed by rocq-bootstrap)", runner.Bin))
+	} else {
+		onLog(fmt.Sprintf("  \u2713 opam: %s", runner.Bin))
+	}
 
-	out, err := exec.Command("opam", "--version").Output()
-	if err == nil {
-		ver := strings.TrimSpace(string(out))
+	if ver, err := runner.Version(); err == nil {
 		onLog(fmt.Sprintf("  Version: %s", ver))
 		if !strings.HasPrefix(ver, "2.") {
 			onLog("  \u26a0 opam >= 2.x recommended")
@@ -56,35 +74,63 @@ func checkOpam(onLog func(string)) bool {
 	return true
 }
 
-func checkSwitches(onLog func(string)) bool {
-	out, err := exec.Command("opam", "switch", "list", "--short").Output()
-	if err != nil {
-		onLog("  (could not list opam switches)")
+// checkSwitches lists the Rocq/Coq switches of every known opam root, the
+// user's default root as well as the dedicated rocq-bootstrap root.
+func checkSwitches(runner *opam.Runner, onLog func(string)) bool {
+	roots := opam.KnownRoots()
+	if len(roots) == 0 {
+		onLog("  \u26a0 No initialized opam root found")
 		return false
 	}
 
 	found := false
-	for _, line := range strings.Split(string(out), "\n") {
-		name := strings.TrimSpace(line)
-		if name == "" {
+	for _, root := range roots {
+		rootRunner := &opam.Runner{Bin: runner.Bin, Root: root}
+		onLog(fmt.Sprintf("  Root: %s", root))
+
+		names, err := platformSwitches(rootRunner)
+		if err != nil {
+			onLog("    (could not list opam switches)")
 			continue
 		}
-		if strings.HasPrefix(name, "CP.") || strings.HasPrefix(name, "coq-") {
+		for _, name := range names {
 			found = true
-			onLog(fmt.Sprintf("  \u2713 %s", name))
-			checkSwitchPackages(name, onLog)
-			checkSwitchBinaries(name, onLog)
+			if opam.IsLocalSwitch(name) {
+				onLog(fmt.Sprintf("  \u2713 %s (local switch)", name))
+			} else {
+				onLog(fmt.Sprintf("  \u2713 %s", name))
+			}
+			checkSwitchPackages(rootRunner, name, onLog)
+			checkSwitchBinaries(rootRunner, name, onLog)
 		}
 	}
 
 	if !found {
-		onLog("  \u26a0 No Rocq/Coq Platform switches found (CP.* or coq-*)")
+		onLog("  \u26a0 No Rocq/Coq Platform switches found (CP.*, coq-* or local)")
 	}
 	return found
 }
 
-func checkSwitchPackages(switchName string, onLog func(string)) {
-	out, err := exec.Command("opam", "list", "--switch="+switchName, "--installed", "--short", "-V").Output()
+// platformSwitches returns the CP.*, coq-* and Rocq/Coq local switches of
+// the runner's root.
+func platformSwitches(runner *opam.Runner) ([]string, error) {
+	out, err := runner.Command("switch", "list", "--short").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(out), "\n") {
+		name := strings.TrimSpace(line)
+		if opam.IsPlatformSwitch(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func checkSwitchPackages(runner *opam.Runner, switchName string, onLog func(string)) {
+	out, err := runner.Command("list", "--switch="+switchName, "--installed", "--short", "-V").Output()
 	if err != nil {
 		onLog("    (could not list packages)")
 		return
@@ -112,8 +158,8 @@ func checkSwitchPackages(switchName string, onLog func(string)) {
 	}
 }
 
-func checkSwitchBinaries(switchName string, onLog func(string)) {
-	out, err := exec.Command("opam", "var", "--switch="+switchName, "bin").Output()
+func checkSwitchBinaries(runner *opam.Runner, switchName string, onLog func(string)) {
+	out, err := runner.Command("var", "--switch="+switchName, "bin").Output()
 	if err != nil {
 		return
 	}
@@ -153,15 +199,135 @@ func checkBinaries(onLog func(string)) {
 	}
 }
 
-func checkVSCode(onLog func(string)) (vsrocqFound, vscoqFound bool) {
-	codeBin, err := vscode.FindCode()
-	if err != nil {
+// checkVSCode reports the VSCode-family editors and their Rocq/Coq
+// extensions, and whether extension and the one conflicting with it are
+// installed in any of them.
+func checkVSCode(extension string, onLog func(string)) (found, conflictFound bool) {
+	editors := vscode.FindEditors()
+	if wsl.Detect() {
+		checkWSL(editors, onLog)
+	}
+	if len(editors) == 0 {
 		onLog("  VSCode not found")
 		return false, false
 	}
-	onLog(fmt.Sprintf("  CLI: %s", codeBin))
+	serverExtension, serverVersion := workspaceLanguageServer()
+	if serverVersion != "" {
+		onLog(fmt.Sprintf("  Workspace language server: %s", serverVersion))
+	}
+	profile := workspaceProfile()
+	if profile != "" {
+		onLog(fmt.Sprintf("  Workspace VSCode profile: %s", profile))
+	}
+	for _, e := range editors {
+		onLog(fmt.Sprintf("  %s CLI: %s", e.Label(), e.Bin))
+		f, c := checkExtensions(e.Bin, profile, extension, serverExtension, serverVersion, onLog)
+		found = found || f
+		conflictFound = conflictFound || c
+	}
+	return found, conflictFound
+}
+
+// checkWSL reports the WSL distribution and whether a Windows editor is
+// available to use it through Remote-WSL, the extensions of which are the
+// ones installed in WSL.
+func checkWSL(editors []vscode.Editor, onLog func(string)) {
+	distro := wsl.Distro()
+	if distro == "" {
+		distro = "(unknown distribution)"
+	}
+	onLog(fmt.Sprintf("  WSL: %s", distro))
+	for _, e := range editors {
+		if e.Remote == vscode.RemoteWSL {
+			onLog("  \u2713 Windows VSCode used through Remote-WSL (extensions listed below are the WSL ones)")
+			return
+		}
+	}
+	onLog("  \u26a0 No Windows VSCode found: install it on Windows to use it through Remote-WSL")
+}
+
+// workspaceProfile returns the VSCode profile the workspace was set up
+// with, or "" for the default profile.
+func workspaceProfile() string {
+	wsDir, err := settings.Load().Workspace()
+	if err != nil {
+		return ""
+	}
+	reg, err := registry.Load()
+	if err != nil {
+		return ""
+	}
+	if e := reg.Find(wsDir); e != nil {
+		return e.Profile
+	}
+	return ""
+}
+
+// workspaceExtension returns the extension the current workspace needs:
+// the one its settings configure, or else the one of the release it is
+// registered with, VSRocq if unknown.
+func workspaceExtension() string {
+	if extensionID, _ := workspaceLanguageServer(); extensionID != "" {
+		return extensionID
+	}
+	if wsDir, err := settings.Load().Workspace(); err == nil {
+		if reg, err := registry.Load(); err == nil {
+			if e := reg.Find(wsDir); e != nil && e.RocqVersion != "" {
+				return vscode.ExtensionIDForVersion(e.RocqVersion)
+			}
+		}
+	}
+	return vscode.RocqExtensionID
+}
+
+// workspaceLanguageServer returns the extension the VSCode settings of the
+// workspace configure and the version of the language server they point
+// it at, or empty strings. Without a path in the workspace settings, those
+// of the user settings file the workspace was set up with are returned.
+func workspaceLanguageServer() (extensionID, version string) {
+	wsDir, err := settings.Load().Workspace()
+	if err != nil {
+		return "", ""
+	}
+	wsSettings, _ := workspace.ReadVSCodeSettings(wsDir)
+	if extensionID, version = languageServer(wsSettings); extensionID != "" {
+		return extensionID, version
+	}
+	reg, err := registry.Load()
+	if err != nil {
+		return "", ""
+	}
+	if e := reg.Find(wsDir); e != nil && e.UserSettings != "" {
+		userSettings, _ := workspace.ReadUserSettings(e.UserSettings)
+		return languageServer(userSettings)
+	}
+	return "", ""
+}
+
+// languageServer returns the extension vsSettings configure and the
+// version of the language server they point it at, or empty strings.
+func languageServer(vsSettings map[string]interface{}) (extensionID, version string) {
+	if p, ok := vsSettings["vsrocq.path"].(string); ok && p != "" {
+		return vscode.RocqExtensionID, vscode.LanguageServerVersion(p)
+	}
+	if p, ok := vsSettings["vscoq.path"].(string); ok && p != "" {
+		return vscode.CoqExtensionID, vscode.LanguageServerVersion(p)
+	}
+	return "", ""
+}
 
-	out, err := exec.Command(codeBin, "--list-extensions", "--show-versions").Output()
+// checkExtensions reports the Rocq/Coq extensions of the VSCode-family
+// editor with the given CLI in the profile ("" for the default one),
+// whether extension and the one conflicting with it are installed, and
+// whether the one of serverExtension works with the language server of
+// the given version (if known).
+func checkExtensions(codeBin, profile, extension, serverExtension, serverVersion string, onLog func(string)) (found, conflictFound bool) {
+	conflict := vscode.ConflictingExtensionID(extension)
+	args := []string{"--list-extensions", "--show-versions"}
+	if profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	out, err := exec.Command(codeBin, args...).Output()
 	if err != nil {
 		onLog("  (could not list extensions)")
 		return false, false
@@ -176,54 +342,84 @@ func checkVSCode(onLog func(string)) (vsrocqFound, vscoqFound bool) {
 		if strings.Contains(lower, "rocq") || strings.Contains(lower, "coq") {
 			onLog(fmt.Sprintf("    %s", line))
 			anyExt = true
-			if strings.Contains(lower, "vsrocq") {
-				vsrocqFound = true
-			}
-			if strings.Contains(lower, "vscoq") {
-				vscoqFound = true
+			id, version, ok := strings.Cut(line, "@")
+			found = found || strings.EqualFold(id, extension)
+			conflictFound = conflictFound || strings.EqualFold(id, conflict)
+			if ok && serverVersion != "" && strings.EqualFold(id, serverExtension) && !vscode.CompatibleVersions(version, serverVersion) {
+				onLog(fmt.Sprintf("    \u26a0 %s %s is not compatible with language server %s", id, version, serverVersion))
 			}
 		}
 	}
 	if !anyExt {
 		onLog("    (no Rocq/Coq extensions)")
 	}
-	if !vsrocqFound {
-		onLog("  \u26a0 vsrocq extension not found")
+	if !found {
+		onLog(fmt.Sprintf("  \u26a0 %s extension not found", extension))
 	}
-	if vscoqFound {
-		onLog("  \u26a0 vscoq extension detected (deprecated, use vsrocq instead)")
+	if conflictFound {
+		onLog(fmt.Sprintf("  \u26a0 %s extension detected, which conflicts with %s", conflict, extension))
 	}
 
-	return vsrocqFound, vscoqFound
+	return found, conflictFound
+}
+
+func checkEmacs(onLog func(string)) {
+	emacsBin, err := emacs.FindEmacs()
+	if err != nil {
+		onLog("  Emacs not found")
+		return
+	}
+	onLog(fmt.Sprintf("  Emacs: %s", emacsBin))
+
+	if emacs.HasPackage(emacsBin, emacs.ProofGeneral) {
+		onLog("  \u2713 Proof General installed")
+	} else {
+		onLog("  \u26a0 Proof General not found")
+	}
+	if emacs.HasPackage(emacsBin, emacs.CompanyCoq) {
+		onLog("  \u2713 company-coq installed")
+	}
 }
 
 func checkWorkspace(onLog func(string)) {
-	home, err := os.UserHomeDir()
+	wsDir, err := settings.Load().Workspace()
 	if err != nil {
-		onLog("  (could not determine home directory)")
+		onLog(fmt.Sprintf("  (could not determine workspace directory: %v)", err))
 		return
 	}
 
-	wsDir := filepath.Join(home, "rocq-workspace")
 	if info, err := os.Stat(wsDir); err == nil && info.IsDir() {
 		onLog(fmt.Sprintf("  \u2713 %s", wsDir))
 
-		settingsPath := filepath.Join(wsDir, ".vscode", "settings.json")
-		if data, err := os.ReadFile(settingsPath); err == nil {
-			var settings map[string]interface{}
-			if err := json.Unmarshal(data, &settings); err == nil {
-				if v, ok := settings["vsrocq.path"]; ok {
-					onLog(fmt.Sprintf("  settings.json: vsrocq.path = %v", v))
-				} else {
-					onLog("  settings.json: vsrocq.path not set")
+		wsPathSet := false
+		if settings, err := workspace.ReadVSCodeSettings(wsDir); err == nil {
+			for _, key := range workspace.LanguageServerKeys {
+				if v, ok := settings[key]; ok && v != nil {
+					onLog(fmt.Sprintf("  settings.json: %s = %v", key, v))
+					wsPathSet = true
 				}
 			}
-		} else {
+			if !wsPathSet {
+				onLog("  settings.json: no language server path, the user settings apply")
+			}
+		} else if os.IsNotExist(err) {
 			onLog("  .vscode/settings.json not found")
+		} else {
+			onLog(fmt.Sprintf("  \u26a0 .vscode/settings.json: %v", err))
+		}
+		if !checkUserSettings(wsDir, onLog) && !wsPathSet {
+			onLog("  \u26a0 No language server path in the workspace or user settings")
+		}
+
+		checkEmacsConfig(wsDir, onLog)
+		for _, f := range []string{workspace.NeovimFile, workspace.VimFile} {
+			if _, err := os.Stat(filepath.Join(wsDir, f)); err == nil {
+				onLog(fmt.Sprintf("  \u2713 %s present", f))
+			}
 		}
 
 		// Check activation scripts
-		for _, script := range []string{"activate.sh", "activate-shell.sh"} {
+		for _, script := range []string{"activate.sh", "activate.fish", "activate.nu", "activate.ps1", "activate-shell.sh"} {
 			scriptPath := filepath.Join(wsDir, script)
 			if _, err := os.Stat(scriptPath); err == nil {
 				onLog(fmt.Sprintf("  \u2713 %s present", script))
@@ -236,7 +432,62 @@ func checkWorkspace(onLog func(string)) {
 	}
 }
 
-func checkIssues(onLog func(string), opamFound, installFound, vsrocqFound, vscoqFound bool) {
+// checkUserSettings reports the language server paths in the user settings
+// of the VSCode-family editors, for the profile the workspace opens in,
+// warns when the workspace settings, which override them, set a different
+// one, and returns whether any user settings set one.
+func checkUserSettings(wsDir string, onLog func(string)) (found bool) {
+	wsSettings, _ := workspace.ReadVSCodeSettings(wsDir)
+	profile := workspaceProfile()
+	for _, e := range vscode.FindEditors() {
+		path, err := e.UserSettingsPath(profile)
+		if err != nil {
+			continue
+		}
+		userSettings, err := workspace.ReadUserSettings(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			onLog(fmt.Sprintf("  \u26a0 %s user settings: %v", e.Label(), err))
+			continue
+		}
+		for _, key := range workspace.LanguageServerKeys {
+			userValue, ok := userSettings[key]
+			if !ok || userValue == nil {
+				continue
+			}
+			found = true
+			onLog(fmt.Sprintf("  %s user settings: %s = %v", e.Label(), key, userValue))
+			if wsValue, ok := wsSettings[key]; ok && wsValue != nil && fmt.Sprint(wsValue) != fmt.Sprint(userValue) {
+				onLog(fmt.Sprintf("  \u26a0 %s differs between the workspace (%v) and the %s user settings (%v); the workspace one applies", key, wsValue, e.Label(), userValue))
+			}
+		}
+	}
+	return found
+}
+
+// checkEmacsConfig reports the binaries the workspace's Emacs file points
+// Proof General at, if the workspace has one.
+func checkEmacsConfig(wsDir string, onLog func(string)) {
+	ec, err := workspace.ReadEmacsConfig(wsDir)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		onLog(fmt.Sprintf("  \u26a0 %s: %v", workspace.EmacsFile, err))
+		return
+	}
+	for _, dir := range ec.Path {
+		if _, err := os.Stat(filepath.Join(dir, "coqtop")); err == nil {
+			onLog(fmt.Sprintf("  %s: exec-path %s", workspace.EmacsFile, dir))
+		} else {
+			onLog(fmt.Sprintf("  \u26a0 %s: no coqtop in %s", workspace.EmacsFile, dir))
+		}
+	}
+}
+
+func checkIssues(runner *opam.Runner, onLog func(string), opamFound, installFound bool, extension string, found, conflictFound bool) {
 	anyIssue := false
 
 	if !opamFound {
@@ -251,11 +502,13 @@ func checkIssues(onLog func(string), opamFound, installFound, vsrocqFound, vscoq
 
 	// Check for multiple CP.* switches
 	if opamFound {
-		out, _ := exec.Command("opam", "switch", "list", "--short").Output()
 		cpCount := 0
-		for _, line := range strings.Split(string(out), "\n") {
-			if strings.HasPrefix(strings.TrimSpace(line), "CP.") {
-				cpCount++
+		for _, root := range opam.KnownRoots() {
+			names, _ := platformSwitches(&opam.Runner{Bin: runner.Bin, Root: root})
+			for _, name := range names {
+				if strings.HasPrefix(name, "CP.") {
+					cpCount++
+				}
 			}
 		}
 		if cpCount > 1 {
@@ -264,13 +517,17 @@ func checkIssues(onLog func(string), opamFound, installFound, vsrocqFound, vscoq
 		}
 	}
 
-	if !vsrocqFound {
-		onLog("  \u26a0 vsrocq extension not installed \u2014 required for Rocq support in VSCode")
+	language := "Rocq"
+	if extension == vscode.CoqExtensionID {
+		language = "Coq"
+	}
+	if !found {
+		onLog(fmt.Sprintf("  \u26a0 %s extension not installed \u2014 required for %s support in VSCode", extension, language))
 		anyIssue = true
 	}
 
-	if vscoqFound {
-		onLog("  \u26a0 vscoq extension is installed \u2014 deprecated, may conflict with vsrocq")
+	if conflictFound {
+		onLog(fmt.Sprintf("  \u26a0 %s extension is installed \u2014 conflicts with %s (disable it for the workspace, or rerun with --uninstall-conflicting)", vscode.ConflictingExtensionID(extension), extension))
 		anyIssue = true
 	}
 
@@ -278,3 +535,32 @@ func checkIssues(onLog func(string), opamFound, installFound, vsrocqFound, vscoq
 		onLog("  (no issues detected)")
 	}
 }
+
+func checkRegisteredWorkspaces(runner *opam.Runner, onLog func(string)) {
+	reg, err := registry.Load()
+	if err != nil {
+		onLog(fmt.Sprintf("  \u26a0 %v", err))
+		return
+	}
+	if len(reg.Workspaces) == 0 {
+		onLog("  (none)")
+		return
+	}
+	for _, e := range reg.Workspaces {
+		problems := e.Problems()
+		if e.Switch != "" && runner.Bin != "" {
+			names, err := platformSwitches(&opam.Runner{Bin: runner.Bin, Root: e.OpamRoot})
+			if err == nil && !slices.Contains(names, e.Switch) {
+				problems = append(problems, fmt.Sprintf("opam switch %s not found", e.Switch))
+			}
+		}
+		if len(problems) == 0 {
+			onLog(fmt.Sprintf("  \u2713 %s \u2192 %s", e.Dir, e.Environment()))
+			continue
+		}
+		onLog(fmt.Sprintf("  \u26a0 %s \u2192 %s", e.Dir, e.Environment()))
+		for _, p := range problems {
+			onLog("      " + p)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package emacs
+
+import (
+	"fmt"
+	"os/exec"
+
+	sharedemacs "github.com/justme0606/rocq-bootstrap/shared/emacs"
+)
+
+// Emacs packages set up by the installer.
+const (
+	ProofGeneral = sharedemacs.ProofGeneral
+	CompanyCoq   = sharedemacs.CompanyCoq
+)
+
+// HasPackage reports whether Emacs finds the given package.
+func HasPackage(emacsBin, pkg string) bool {
+	return sharedemacs.HasPackage(emacsBin, pkg)
+}
+
+// InstallPackage installs the given package from MELPA if not already present.
+func InstallPackage(emacsBin, pkg string) error {
+	return sharedemacs.InstallPackage(emacsBin, pkg)
+}
+
+// FindEmacs searches for the Emacs executable.
+func FindEmacs() (string, error) {
+	// Try PATH first
+	path, err := exec.LookPath("emacs")
+	if err == nil {
+		return path, nil
+	}
+
+	// Try common Linux install locations
+	candidates := []string{
+		"/usr/bin/emacs",
+		"/snap/bin/emacs",
+		"/usr/local/bin/emacs",
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c); err == nil {
+			return c, nil
+		}
+	}
+
+	return "", fmt.Errorf("Emacs not found in PATH or common locations")
+}
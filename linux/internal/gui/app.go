@@ -3,22 +3,135 @@ package gui
 import (
 	"fmt"
 	"io/fs"
+	"path/filepath"
+	"strings"
 	"time"
 
 	sharedgui "github.com/justme0606/rocq-bootstrap/shared/gui"
+	"github.com/justme0606/rocq-bootstrap/shared/settings"
 
 	"github.com/justme0606/rocq-bootstrap/linux/internal/doctor"
 	"github.com/justme0606/rocq-bootstrap/linux/internal/installer"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/lockfile"
 	"github.com/justme0606/rocq-bootstrap/linux/internal/manifest"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/opam"
 	"github.com/justme0606/rocq-bootstrap/linux/internal/releases"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/vscode"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/workspace"
 )
 
 const totalSteps = 7
 
+// Options holds the command-line settings passed to the GUI.
+type Options struct {
+	ShowLog              bool   // show the log panel
+	OpamRoot             string // dedicated opam root; empty means the user's default root
+	LocalSwitch          bool   // create a project-local switch in the workspace
+	Template             string // workspace template ID; empty means the default template
+	WorkspaceDir         string // workspace directory; empty means the remembered or default one
+	CodeWorkspace        bool   // also create a .code-workspace file
+	Editor               string // editor to configure; empty means VSCode, or Emacs if VSCode is not found
+	CodeEditor           string // VSCode-family editor to use, by CLI or label; empty means the first one found
+	VSIX                 string // local .vsix of the extension to install; empty means the manifest's or the Marketplace's
+	SettingsScope        string // scope of the language server setting (workspace.ScopeWorkspace, ScopeUser); empty means the workspace
+	Profile              string // dedicated VSCode profile; empty means the default profile
+	UninstallConflicting bool   // uninstall the conflicting VSCoq/VSRocq extension rather than disable it in the window opened by the installer
+	CompanyCoq           bool   // with Emacs, also set up company-coq
+	Build                string // build system to set up in the workspace; empty means none
+	Git                  bool   // initialize the workspace as a git repository
+	Direnv               bool   // also write a .envrc for direnv
+}
+
 // Run creates and runs the GUI application.
-func Run(m *manifest.Manifest, templates fs.FS, icon []byte, version string, showLog bool) {
+func Run(m *manifest.Manifest, templates fs.FS, icon []byte, version string, opts *Options) {
 	currentManifest := m
 
+	dedicatedRoot := opts.OpamRoot
+	if dedicatedRoot == "" {
+		dedicatedRoot = opam.IsolatedRoot()
+	}
+	isolatedRoot := &sharedgui.CheckOption{
+		Label:   fmt.Sprintf("Use a dedicated opam root (%s)", dedicatedRoot),
+		Checked: opts.OpamRoot != "",
+	}
+	localSwitch := &sharedgui.CheckOption{
+		Label:   "Create a project-local switch in the workspace",
+		Checked: opts.LocalSwitch,
+	}
+	workspaceDir := &sharedgui.FolderOption{Label: "Workspace:", Path: opts.WorkspaceDir}
+	if workspaceDir.Path == "" {
+		workspaceDir.Path, _ = settings.Load().Workspace()
+	}
+	codeWorkspace := &sharedgui.CheckOption{
+		Label:   "Also create a .code-workspace file",
+		Checked: opts.CodeWorkspace,
+	}
+	git := &sharedgui.CheckOption{
+		Label:   "Initialize the workspace as a git repository",
+		Checked: opts.Git,
+	}
+	direnv := &sharedgui.CheckOption{
+		Label:   "Also write a .envrc for direnv",
+		Checked: opts.Direnv,
+	}
+	// The profile is named by --profile, or else the default one.
+	profileName := opts.Profile
+	if profileName == "" {
+		profileName = vscode.DefaultProfile
+	}
+	profile := &sharedgui.CheckOption{
+		Label:   fmt.Sprintf("Use a dedicated VSCode profile (%s)", profileName),
+		Checked: opts.Profile != "",
+	}
+	uninstallConflicting := &sharedgui.CheckOption{
+		Label:   "Uninstall a conflicting VSCoq/VSRocq extension instead of disabling it for this session",
+		Checked: opts.UninstallConflicting,
+	}
+	companyCoq := &sharedgui.CheckOption{
+		Label:   "With Emacs, also set up company-coq",
+		Checked: opts.CompanyCoq,
+	}
+	editor := &sharedgui.SelectOption{Label: "Editor:", Selected: opts.Editor}
+	editor.Add("", "VSCode, or Emacs if VSCode is not found")
+	editor.Add(workspace.EditorVSCode, "VSCode")
+	editor.Add(workspace.EditorEmacs, "Emacs (Proof General)")
+	editor.Add(workspace.EditorNeovim, "Neovim (language server) / Vim (Coqtail)")
+	// The VSCode-family editor is only asked for when several are installed.
+	codeEditor := &sharedgui.SelectOption{Label: "VSCode editor:", Selected: opts.CodeEditor}
+	codeEditors := vscode.FindEditors()
+	if e, err := vscode.SelectEditor(codeEditors, opts.CodeEditor); err == nil {
+		codeEditor.Selected = e.Bin
+	}
+	for _, e := range codeEditors {
+		codeEditor.Add(e.Bin, fmt.Sprintf("%s (%s)", e.Label(), e.Bin))
+	}
+	settingsScope := &sharedgui.SelectOption{Label: "Language server setting:", Selected: opts.SettingsScope}
+	if settingsScope.Selected == "" {
+		settingsScope.Selected = workspace.ScopeWorkspace
+	}
+	settingsScope.Add(workspace.ScopeWorkspace, "Workspace (.vscode/settings.json)")
+	settingsScope.Add(workspace.ScopeUser, "VSCode user settings (all folders)")
+	build := &sharedgui.SelectOption{Label: "Build:", Selected: opts.Build}
+	build.Add("", "None")
+	build.Add(workspace.BuildMake, "Makefile (rocq makefile)")
+	build.Add(workspace.BuildDune, "dune")
+	template := &sharedgui.SelectOption{Label: "Template:", Selected: opts.Template}
+	if template.Selected == "" {
+		template.Selected = workspace.DefaultTemplate
+	}
+	// A broken template tree is reported by the installer when it creates the workspace.
+	if list, err := workspace.ListTemplates(templates); err == nil {
+		for _, t := range list {
+			template.Add(t.ID, t.Name)
+		}
+	}
+
+	options := []sharedgui.Option{workspaceDir, template, build, editor}
+	if len(codeEditors) > 1 {
+		options = append(options, codeEditor)
+	}
+	options = append(options, settingsScope, git, codeWorkspace, profile, uninstallConflicting, companyCoq, isolatedRoot, localSwitch, direnv)
+
 	cfg := &sharedgui.AppConfig{
 		Version:    version,
 		TotalSteps: totalSteps,
@@ -29,11 +142,11 @@ func Run(m *manifest.Manifest, templates fs.FS, icon []byte, version string, sho
 			"Configure repository",
 			"Install Rocq packages",
 			"Create workspace",
-			"Configure VSCode",
+			"Configure editor",
 		},
 		RocqVersion:     m.RocqVersion,
 		PlatformRelease: m.PlatformRelease,
-		ShowLog:         showLog,
+		ShowLog:         opts.ShowLog,
 		Icon:            icon,
 
 		FetchReleases:    releases.FetchReleases,
@@ -43,6 +156,8 @@ func Run(m *manifest.Manifest, templates fs.FS, icon []byte, version string, sho
 			if err != nil {
 				return err
 			}
+			// Package picks don't describe opam itself: keep the embedded pin.
+			newManifest.Assets.Linux.X86_64.Opam.Bootstrap = m.Assets.Linux.X86_64.Opam.Bootstrap
 			currentManifest = newManifest
 			return nil
 		},
@@ -58,19 +173,100 @@ func Run(m *manifest.Manifest, templates fs.FS, icon []byte, version string, sho
 			return fmt.Sprintf("Install new (%s)", installer.SwitchName(currentManifest.RocqVersion, currentManifest.PlatformRelease))
 		},
 
+		Options: options,
+
 		RunDoctor: func(onLog func(string)) {
 			doctor.Run(onLog)
 		},
 
+		RelinkWorkspace: func(dir, target string) error {
+			_, err := installer.Relink(dir, target)
+			return err
+		},
+
 		RunInstall: func(ctx *sharedgui.InstallContext, existingSelection string, skipInstall bool) {
-			runInstall(ctx, currentManifest, templates, skipInstall)
+			cfg := &installer.Config{
+				Manifest:             currentManifest,
+				Templates:            templates,
+				Template:             template.Selected,
+				WorkspaceDir:         workspaceDir.Path,
+				CodeWorkspace:        codeWorkspace.Checked,
+				Editor:               editor.Selected,
+				CodeEditor:           codeEditor.Selected,
+				SettingsScope:        settingsScope.Selected,
+				VSIX:                 opts.VSIX,
+				UninstallConflicting: uninstallConflicting.Checked,
+				CompanyCoq:           companyCoq.Checked,
+				Build:                build.Selected,
+				Git:                  git.Checked,
+				Direnv:               direnv.Checked,
+				LocalSwitch:          localSwitch.Checked,
+				SkipInstall:          skipInstall,
+			}
+			if isolatedRoot.Checked {
+				cfg.OpamRoot = dedicatedRoot
+			}
+			if profile.Checked {
+				cfg.Profile = profileName
+			}
+			if skipInstall {
+				inst, ok := installer.LookupInstallation(existingSelection)
+				if !ok {
+					ctx.Abort(fmt.Sprintf("opam switch %s is no longer available", existingSelection))
+					return
+				}
+				cfg.ExistingSwitch = inst.Switch
+				cfg.OpamRoot = inst.Root
+				if cfg.OpamRoot == opam.DefaultRoot() {
+					cfg.OpamRoot = ""
+				}
+			}
+			runInstall(ctx, cfg)
 		},
+
+		FileActions: []*sharedgui.FileAction{{
+			Label:      "Open lock file\u2026",
+			Extensions: []string{".json"},
+			Run: func(ctx *sharedgui.InstallContext, path string) {
+				lock, err := lockfile.Read(path)
+				if err != nil {
+					ctx.Abort(err.Error())
+					return
+				}
+				cfg := &installer.Config{
+					Manifest:             m,
+					Templates:            templates,
+					Template:             template.Selected,
+					WorkspaceDir:         workspaceDir.Path,
+					CodeWorkspace:        codeWorkspace.Checked,
+					Editor:               editor.Selected,
+					CodeEditor:           codeEditor.Selected,
+					SettingsScope:        settingsScope.Selected,
+					VSIX:                 opts.VSIX,
+					UninstallConflicting: uninstallConflicting.Checked,
+					CompanyCoq:           companyCoq.Checked,
+					Build:                build.Selected,
+					Git:                  git.Checked,
+					Direnv:               direnv.Checked,
+					Lock:                 lock,
+				}
+				if isolatedRoot.Checked {
+					cfg.OpamRoot = dedicatedRoot
+				}
+				if profile.Checked {
+					cfg.Profile = profileName
+				}
+				runInstall(ctx, cfg)
+			},
+		}},
 	}
 
 	sharedgui.Run(cfg)
 }
 
-func runInstall(ctx *sharedgui.InstallContext, m *manifest.Manifest, templates fs.FS, skipInstall bool) {
+// runInstall runs the installer with cfg, reporting progress and the
+// outcome in the GUI.
+func runInstall(ctx *sharedgui.InstallContext, cfg *installer.Config) {
 	startTime := time.Now()
 
 	logger, err := installer.NewLogger()
@@ -81,24 +277,17 @@ func runInstall(ctx *sharedgui.InstallContext, m *manifest.Manifest, templates f
 		defer logger.Close()
 	}
 
-	switchName := installer.SwitchName(m.RocqVersion, m.PlatformRelease)
-
-	cfg := &installer.Config{
-		Manifest:    m,
-		Templates:   templates,
-		SkipInstall: skipInstall,
-		Logger:      logger,
-		OnStep: func(step int, label string, fraction float64) {
-			// Show infinite progress bar during long opam operations (steps 2-5)
-			if step >= 2 && step <= 5 && fraction < 1.0 {
-				ctx.ProgressBar.Hide()
-				ctx.InfiniteBar.Show()
-			} else {
-				ctx.InfiniteBar.Hide()
-				ctx.ProgressBar.Show()
-			}
-			ctx.OnStep(step, label, fraction)
-		},
+	cfg.Logger = logger
+	cfg.OnStep = func(step int, label string, fraction float64) {
+		// Show infinite progress bar during long opam operations (steps 2-5)
+		if step >= 2 && step <= 5 && fraction < 1.0 {
+			ctx.ProgressBar.Hide()
+			ctx.InfiniteBar.Show()
+		} else {
+			ctx.InfiniteBar.Hide()
+			ctx.ProgressBar.Show()
+		}
+		ctx.OnStep(step, label, fraction)
 	}
 
 	result, err := installer.Run(cfg)
@@ -107,49 +296,71 @@ func runInstall(ctx *sharedgui.InstallContext, m *manifest.Manifest, templates f
 			logger.Log("ERROR: %v", err)
 		}
 		ctx.LogPanel.Append(fmt.Sprintf("ERROR: %v", err))
-		sharedgui.ShowError(ctx.Window, ctx.InstallBtn, err.Error())
+		ctx.Abort(err.Error())
 		return
 	}
 
 	ctx.ProgressBar.SetValue(1.0)
 
 	elapsed := sharedgui.FormatDuration(time.Since(startTime))
+	switchName := installer.Installation{Root: result.OpamRoot, Switch: result.SwitchName}.Label()
+	activateHint := "Activate with: source " + filepath.Join(result.WorkspaceDir, "activate.sh")
 
-	if !result.VSCodeFound {
+	reproNote := ""
+	if len(result.Differences) > 0 {
+		reproNote = fmt.Sprintf("%d item(s) could not be reproduced exactly from the lock file:\n  %s\n\n",
+			len(result.Differences), strings.Join(result.Differences, "\n  "))
+		ctx.LogPanel.Append("Not reproduced exactly from the lock file:")
+		for _, d := range result.Differences {
+			ctx.LogPanel.Append("  " + d)
+		}
+	}
+
+	if !result.VSCodeFound && result.Editor == "" {
 		ctx.StatusLabel.SetText(fmt.Sprintf("Rocq Platform installed in %s — VSCode not found", elapsed))
 		ctx.LogPanel.Append(fmt.Sprintf("Rocq Platform installed successfully in %s.", elapsed))
 		ctx.LogPanel.Append("VSCode was not found. Install VSCode then re-run this installer to configure the workspace.")
 		ctx.LogPanel.Append(fmt.Sprintf("Opam switch: %s", switchName))
-		ctx.LogPanel.Append("Activate with: source ~/rocq-workspace/activate.sh")
+		ctx.LogPanel.Append(activateHint)
 
 		if ctx.Checklist != nil {
 			ctx.Checklist.AppendSummary("")
 			ctx.Checklist.AppendSummary(fmt.Sprintf("Rocq Platform installed successfully in %s.", elapsed))
 			ctx.Checklist.AppendSummary(fmt.Sprintf("Opam switch: %s", switchName))
-			ctx.Checklist.AppendSummary("Activate with: source ~/rocq-workspace/activate.sh")
+			ctx.Checklist.AppendSummary(activateHint)
 		}
 
 		sharedgui.ShowVSCodeDialog(ctx.Window)
 		return
 	}
 
+	// Emacs and Neovim need a step from the user to load the workspace
+	// configuration.
+	editorNote := ""
+	if hint := workspace.SetupHint(result.Editor, result.WorkspaceDir); hint != "" {
+		editorNote = hint + "\n\n"
+		activateHint += "\n" + hint
+	}
+
 	ctx.StatusLabel.SetText(fmt.Sprintf("Installation complete! (%s)", elapsed))
 	ctx.LogPanel.Append(fmt.Sprintf("Installation complete! (%s)", elapsed))
 	ctx.LogPanel.Append(fmt.Sprintf("Opam switch: %s", switchName))
-	ctx.LogPanel.Append(fmt.Sprintf("Workspace: ~/%s", installer.WorkspaceName))
-	ctx.LogPanel.Append("Activate with: source ~/rocq-workspace/activate.sh")
+	ctx.LogPanel.Append(fmt.Sprintf("Workspace: %s", result.WorkspaceDir))
+	ctx.LogPanel.Append(activateHint)
 
 	if ctx.Checklist != nil {
 		ctx.Checklist.AppendSummary("")
 		ctx.Checklist.AppendSummary(fmt.Sprintf("Installation complete! (%s)", elapsed))
 		ctx.Checklist.AppendSummary(fmt.Sprintf("Opam switch: %s", switchName))
-		ctx.Checklist.AppendSummary(fmt.Sprintf("Workspace: ~/%s", installer.WorkspaceName))
-		ctx.Checklist.AppendSummary("Activate with: source ~/rocq-workspace/activate.sh")
+		ctx.Checklist.AppendSummary(fmt.Sprintf("Workspace: %s", result.WorkspaceDir))
+		ctx.Checklist.AppendSummary(activateHint)
 	}
 
 	sharedgui.ShowSuccess(ctx.Window,
 		fmt.Sprintf("Rocq Platform has been installed successfully in %s.\n\n", elapsed)+
 			fmt.Sprintf("Opam switch: %s\n", switchName)+
-			fmt.Sprintf("Workspace: ~/%s\n\n", installer.WorkspaceName)+
-			"Activate with:\n  source ~/rocq-workspace/activate.sh")
+			fmt.Sprintf("Workspace: %s\n\n", result.WorkspaceDir)+
+			reproNote+
+			editorNote+
+			fmt.Sprintf("Activate with:\n  source %s", filepath.Join(result.WorkspaceDir, "activate.sh")))
 }
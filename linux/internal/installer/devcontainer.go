@@ -0,0 +1,38 @@
+package installer
+
+import (
+	"fmt"
+
+	"github.com/justme0606/rocq-bootstrap/linux/internal/manifest"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/vscode"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/workspace"
+)
+
+// DevContainer returns the dev container recreating the switch the
+// installer creates from m, with the packages it installs (RocqIDE, which
+// needs a display, aside) and the extension pinned in m.
+func DevContainer(m *manifest.Manifest) *workspace.DevContainer {
+	opamCfg := m.Assets.Linux.X86_64.Opam
+	var pkgs []string
+	for _, pkg := range opamCfg.Packages {
+		if pkg.Optional == "with_rocqide" {
+			continue
+		}
+		pkgs = append(pkgs, fmt.Sprintf("%s=%s", pkg.Name, pkg.Version))
+	}
+	languageServer := "vsrocqtop"
+	if vscode.IsCoq(m.RocqVersion) {
+		languageServer = "vscoqtop"
+	}
+	return &workspace.DevContainer{
+		Name:             fmt.Sprintf("Rocq Platform %s (Rocq %s)", m.PlatformRelease, m.RocqVersion),
+		SwitchName:       SwitchName(m.RocqVersion, m.PlatformRelease),
+		Compiler:         opamCfg.OCamlCompiler,
+		RepoName:         opamCfg.RepoName,
+		RepoURL:          opamCfg.RepoURL,
+		Packages:         pkgs,
+		Extension:        vscode.ExtensionIDForVersion(m.RocqVersion),
+		ExtensionVersion: m.VSCodeExtension.Version,
+		LanguageServer:   languageServer,
+	}
+}
@@ -10,19 +10,22 @@ import (
 	"path/filepath"
 	"strings"
 
+	sharedinstaller "github.com/justme0606/rocq-bootstrap/shared/installer"
+	"github.com/justme0606/rocq-bootstrap/shared/settings"
+
+	"github.com/justme0606/rocq-bootstrap/linux/internal/emacs"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/lockfile"
 	"github.com/justme0606/rocq-bootstrap/linux/internal/manifest"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/opam"
 	"github.com/justme0606/rocq-bootstrap/linux/internal/vscode"
 	"github.com/justme0606/rocq-bootstrap/linux/internal/workspace"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/wsl"
 )
 
 func debugLog(format string, args ...interface{}) {
 	log.Printf(format, args...)
 }
 
-const (
-	WorkspaceName = "rocq-workspace"
-)
-
 // SwitchName returns the opam switch name for a given manifest.
 // Format: CP.<platform_release>~<rocq_major.minor>
 func SwitchName(rocqVersion, platformRelease string) string {
@@ -33,46 +36,127 @@ func SwitchName(rocqVersion, platformRelease string) string {
 	return "CP." + platformRelease + "~" + rocqShort
 }
 
+// ParseSwitchName returns the platform release and Rocq major.minor
+// encoded in a switch name built by SwitchName, or empty strings.
+func ParseSwitchName(name string) (platformRelease, rocqShort string) {
+	rest, ok := strings.CutPrefix(name, "CP.")
+	if !ok {
+		return "", ""
+	}
+	platformRelease, rocqShort, ok = strings.Cut(rest, "~")
+	if !ok {
+		return "", ""
+	}
+	return platformRelease, rocqShort
+}
+
 // StepFunc is called to report progress: step number (1-7), label, and fraction (0.0-1.0).
 type StepFunc func(step int, label string, fraction float64)
 
 // Config holds all parameters for the installation pipeline.
 type Config struct {
-	Manifest    *manifest.Manifest
-	Templates   fs.FS
-	SkipInstall bool // If true, skip opam install steps (reuse existing switch)
-	OnStep      StepFunc
-	Logger      *Logger
+	Manifest             *manifest.Manifest
+	Templates            fs.FS
+	Template             string         // workspace template ID; empty means workspace.DefaultTemplate
+	WorkspaceDir         string         // workspace directory; empty means ~/rocq-workspace
+	CodeWorkspace        bool           // also write a .code-workspace file and open VSCode with it
+	Editor               string         // editor to configure (workspace.EditorVSCode, EditorEmacs, EditorNeovim); empty means VSCode, or Emacs if only Emacs is found
+	CodeEditor           string         // VSCode-family editor to use, by CLI or label (see vscode.FindEditors); empty means the first one found
+	SettingsScope        string         // scope of the language server setting (workspace.ScopeWorkspace, ScopeUser); empty means the workspace
+	Profile              string         // VSCode profile to install the extension in and open the workspace with (see vscode.DefaultProfile); empty means the default profile
+	VSIX                 string         // local .vsix of the extension, installed instead of the manifest's or the Marketplace's
+	UninstallConflicting bool           // uninstall the conflicting VSCoq/VSRocq extension rather than disable it in the window opened by the installer
+	CompanyCoq           bool           // with Emacs, also set up company-coq
+	Build                string         // build system to set up (workspace.BuildMake, BuildDune); empty means none
+	Git                  bool           // initialize the workspace as a git repository
+	Direnv               bool           // also write a .envrc for direnv
+	OpamRoot             string         // opam root to install into; empty means opam's default root
+	LocalSwitch          bool           // create a project-local switch inside the workspace
+	SkipInstall          bool           // If true, skip opam install steps (reuse existing switch)
+	ExistingSwitch       string         // Name of the existing switch if reusing
+	Lock                 *lockfile.Lock // if set, reproduce this lock file instead of the manifest packages
+	OnStep               StepFunc
+	Logger               *Logger
 }
 
 // Result holds information about the installation outcome.
 type Result struct {
-	VSCodeFound bool
-	SwitchName  string
+	VSCodeFound  bool
+	Editor       string // editor configured instead of VSCode (workspace.EditorEmacs, EditorNeovim); empty otherwise
+	SwitchName   string
+	OpamRoot     string
+	WorkspaceDir string
+	Differences  []string // what could not be reproduced exactly from the lock file
 }
 
-// FindExistingInstallations returns all opam switches matching CP.* or coq-*.
-func FindExistingInstallations() []string {
+// Installation is a Rocq/Coq opam switch found in a given opam root.
+type Installation struct {
+	Root   string
+	Switch string
+}
+
+// Label returns the text identifying the installation in the GUI. Switches
+// in the default root (or with no root set) are shown by name only.
+func (i Installation) Label() string {
+	if i.Root == "" || i.Root == opam.DefaultRoot() {
+		return i.Switch
+	}
+	return fmt.Sprintf("%s (%s)", i.Switch, i.Root)
+}
+
+// FindInstallations returns all opam switches matching CP.* or coq-*, and
+// the local switches providing Rocq/Coq, in every known opam root.
+func FindInstallations() []Installation {
 	debugLog("[detect] === Searching for existing opam switches ===")
 
-	out, err := exec.Command("opam", "switch", "list", "--short").Output()
-	if err != nil {
-		debugLog("[detect] opam switch list failed: %v", err)
+	opamBin := opam.Locate()
+	if opamBin == "" {
+		debugLog("[detect] opam not found")
 		return nil
 	}
 
-	var switches []string
-	for _, line := range strings.Split(string(out), "\n") {
-		name := strings.TrimSpace(line)
-		if strings.HasPrefix(name, "CP.") || strings.HasPrefix(name, "coq-") {
-			switches = append(switches, name)
+	var found []Installation
+	for _, root := range opam.KnownRoots() {
+		debugLog("[detect] searching opam root %s", root)
+		runner := &opam.Runner{Bin: opamBin, Root: root}
+		out, err := runner.Command("switch", "list", "--short").Output()
+		if err != nil {
+			debugLog("[detect] opam switch list failed in %s: %v", root, err)
+			continue
+		}
+
+		for _, line := range strings.Split(string(out), "\n") {
+			name := strings.TrimSpace(line)
+			if opam.IsPlatformSwitch(name) {
+				debugLog("[detect] => Found: %s in %s", name, root)
+				found = append(found, Installation{Root: root, Switch: name})
+			}
 		}
 	}
 
-	if len(switches) == 0 {
+	if len(found) == 0 {
 		debugLog("[detect] === No existing CP.*/coq-* switch found ===")
 	}
-	return switches
+	return found
+}
+
+// FindExistingInstallations returns the labels of all existing installations.
+func FindExistingInstallations() []string {
+	var labels []string
+	for _, inst := range FindInstallations() {
+		labels = append(labels, inst.Label())
+	}
+	return labels
+}
+
+// LookupInstallation returns the installation whose label is given.
+func LookupInstallation(label string) (Installation, bool) {
+	for _, inst := range FindInstallations() {
+		if inst.Label() == label {
+			return inst, true
+		}
+	}
+	return Installation{}, false
 }
 
 // Run executes the installation pipeline via opam.
@@ -82,21 +166,52 @@ func FindExistingInstallations() []string {
 //  3. Create opam switch
 //  4. Configure rocq-released repo
 //  5. Install Rocq packages
-//  6. Create workspace + activation scripts
-//  7. Configure VSCode + open workspace
+//  6. Create workspace, set up and check the build, git init, activation scripts
+//  7. Configure VSCode + open workspace (or Emacs, Neovim), write the lock file
 func Run(cfg *Config) (*Result, error) {
+	lock := cfg.Lock
+	if lock != nil {
+		// The release comes from the lock file; opam itself is still
+		// bootstrapped from the embedded manifest.
+		m := *cfg.Manifest
+		m.Channel = lock.Channel
+		m.RocqVersion = lock.RocqVersion
+		m.PlatformRelease = lock.PlatformRelease
+		cfg.Manifest = &m
+		cfg.LocalSwitch = opam.IsLocalSwitch(lock.Opam.Switch)
+	}
 	opamCfg := cfg.Manifest.Assets.Linux.X86_64.Opam
-	switchName := SwitchName(cfg.Manifest.RocqVersion, cfg.Manifest.PlatformRelease)
 
-	result := &Result{SwitchName: switchName}
-
-	home, err := os.UserHomeDir()
+	workspaceDir, err := workspace.ResolveDir(cfg.WorkspaceDir)
 	if err != nil {
-		return nil, fmt.Errorf("get home dir: %w", err)
+		return nil, fmt.Errorf("workspace: %w", err)
+	}
+
+	// A local switch is named after the directory holding its _opam, so
+	// opam selects it automatically from within the workspace.
+	switchName := SwitchName(cfg.Manifest.RocqVersion, cfg.Manifest.PlatformRelease)
+	if lock != nil && !cfg.LocalSwitch {
+		switchName = lock.Opam.Switch
+	}
+	if cfg.LocalSwitch {
+		switchName = workspaceDir
+	}
+	if cfg.SkipInstall && cfg.ExistingSwitch != "" {
+		switchName = cfg.ExistingSwitch
+		if opam.IsLocalSwitch(switchName) {
+			workspaceDir = switchName
+		}
 	}
-	workspaceDir := filepath.Join(home, WorkspaceName)
+
+	result := &Result{SwitchName: switchName, OpamRoot: cfg.OpamRoot, WorkspaceDir: workspaceDir}
+
+	var runner *opam.Runner
 
 	if cfg.SkipInstall {
+		runner = &opam.Runner{Bin: opam.Locate(), Root: cfg.OpamRoot}
+		if runner.Bin == "" {
+			return nil, fmt.Errorf("opam: not found")
+		}
 		cfg.Logger.Log("Reusing existing opam switch %s, skipping install steps", switchName)
 		cfg.OnStep(1, "Opam already available, skipping.", 1.0)
 		cfg.OnStep(2, "Skipped (reusing switch).", 1.0)
@@ -106,39 +221,74 @@ func Run(cfg *Config) (*Result, error) {
 	} else {
 		// Step 1: Check/install opam
 		cfg.OnStep(1, "Checking for opam...", 0.0)
-		opamBin, err := ensureOpam(cfg.Logger)
+		opamBin, err := ensureOpam(&opamCfg.Bootstrap, cfg.Logger, func(downloaded, total int64) {
+			if total > 0 {
+				cfg.OnStep(1, "Downloading opam...", float64(downloaded)/float64(total))
+			}
+		})
 		if err != nil {
 			return nil, fmt.Errorf("opam: %w", err)
 		}
-		cfg.Logger.Log("opam found: %s", opamBin)
+		runner = &opam.Runner{Bin: opamBin, Root: cfg.OpamRoot}
+		cfg.Logger.Log("opam found: %s (root: %s)", opamBin, runner.RootDir())
 		cfg.OnStep(1, "Opam found.", 1.0)
 
 		// Step 2: Initialize opam
 		cfg.OnStep(2, "Initializing opam...", 0.0)
-		if err := initOpam(cfg.Logger); err != nil {
+		if err := initOpam(runner, cfg.Logger); err != nil {
 			return nil, fmt.Errorf("opam init: %w", err)
 		}
 		cfg.OnStep(2, "Opam initialized.", 1.0)
 
-		// Step 3: Create switch
+		// Step 3: Create switch (empty when reproducing: the compiler
+		// comes from the switch export)
+		compiler := opamCfg.OCamlCompiler
+		if lock != nil {
+			compiler = ""
+		}
 		cfg.OnStep(3, fmt.Sprintf("Creating opam switch %s...", switchName), 0.0)
-		if err := createSwitch(switchName, opamCfg.OCamlCompiler, cfg.Logger); err != nil {
+		if err := createSwitch(runner, switchName, compiler, cfg.Logger); err != nil {
 			return nil, fmt.Errorf("create switch: %w", err)
 		}
 		cfg.OnStep(3, fmt.Sprintf("Switch %s ready.", switchName), 1.0)
 
 		// Step 4: Configure repo
 		cfg.OnStep(4, "Configuring opam repository...", 0.0)
-		if err := configureRepo(switchName, opamCfg.RepoName, opamCfg.RepoURL, cfg.Logger); err != nil {
+		if lock != nil {
+			// configureRepo gives each repository top priority, so adding
+			// them lowest-ranked first restores the locked order.
+			for i := len(lock.Opam.Repositories) - 1; i >= 0; i-- {
+				repo := lock.Opam.Repositories[i]
+				if err := configureRepo(runner, switchName, repo.Name, repo.URL, cfg.Logger); err != nil {
+					return nil, fmt.Errorf("configure repo %s: %w", repo.Name, err)
+				}
+			}
+		} else if err := configureRepo(runner, switchName, opamCfg.RepoName, opamCfg.RepoURL, cfg.Logger); err != nil {
 			return nil, fmt.Errorf("configure repo: %w", err)
 		}
 		cfg.OnStep(4, "Repository configured.", 1.0)
 
 		// Step 5: Install packages
 		cfg.OnStep(5, "Installing Rocq packages (this may take a while)...", 0.0)
-		if err := installPackages(switchName, opamCfg.Packages, cfg.Logger, func(fraction float64) {
+		onProgress := func(fraction float64) {
 			cfg.OnStep(5, "Installing Rocq packages...", fraction)
-		}); err != nil {
+		}
+		if lock != nil {
+			if err := importSwitch(runner, switchName, lock.Opam.SwitchExport, cfg.Logger, onProgress); err != nil {
+				// The import is all or nothing: get as close as possible
+				// package by package, and report the rest below.
+				cfg.Logger.Log("WARNING: %v; installing the locked packages one by one", err)
+				reproducePackages(runner, switchName, lock.Opam, cfg.Logger, onProgress)
+			}
+			installed, err := runner.InstalledPackages(switchName)
+			if err != nil {
+				return nil, fmt.Errorf("opam list: %w", err)
+			}
+			result.Differences = lockfile.Diff(lock.Opam.Packages, installed)
+			for _, d := range result.Differences {
+				cfg.Logger.Log("Not reproduced: %s", d)
+			}
+		} else if err := installPackages(runner, switchName, opamCfg.Packages, cfg.Logger, onProgress); err != nil {
 			return nil, fmt.Errorf("install packages: %w", err)
 		}
 		cfg.OnStep(5, "Rocq packages installed.", 1.0)
@@ -147,47 +297,167 @@ func Run(cfg *Config) (*Result, error) {
 	// Step 6: Create workspace + activation scripts
 	cfg.OnStep(6, "Creating workspace...", 0.0)
 	cfg.Logger.Log("Creating workspace at %s", workspaceDir)
-	if err := workspace.Create(workspaceDir, cfg.Templates); err != nil {
+	vars := workspace.TemplateVars{
+		RocqVersion:     cfg.Manifest.RocqVersion,
+		PlatformRelease: cfg.Manifest.PlatformRelease,
+		SwitchName:      switchName,
+	}
+	if err := workspace.Create(workspaceDir, cfg.Templates, cfg.Template, vars); err != nil {
 		return nil, fmt.Errorf("workspace: %w", err)
 	}
-	if err := workspace.WriteActivationScripts(workspaceDir, switchName); err != nil {
+	if cfg.Build != "" {
+		cfg.OnStep(6, fmt.Sprintf("Building workspace with %s...", cfg.Build), 0.5)
+		if err := setUpBuild(runner, workspaceDir, switchName, cfg.Build, vscode.IsCoq(cfg.Manifest.RocqVersion), cfg.Logger); err != nil {
+			return nil, fmt.Errorf("build check: %w", err)
+		}
+	}
+	// The initial commit holds the project files, not the activation
+	// scripts bound to this machine's switch.
+	if cfg.Git {
+		initGit(workspaceDir, cfg.Logger)
+	}
+	activation := &workspace.ActivationEnv{SwitchName: switchName, OpamRoot: cfg.OpamRoot, Direnv: cfg.Direnv}
+	if opam.IsManaged(runner.Bin) {
+		activation.OpamBin = runner.Bin
+	}
+	if err := workspace.WriteActivationScripts(workspaceDir, activation); err != nil {
 		return nil, fmt.Errorf("activation scripts: %w", err)
 	}
 	cfg.Logger.Log("Workspace created")
+	if err := settings.RememberWorkspace(workspaceDir); err != nil {
+		cfg.Logger.Log("WARNING: could not remember workspace location: %v", err)
+	}
 	cfg.OnStep(6, "Workspace created.", 1.0)
 
-	// Step 7: Check for VSCode and configure
-	cfg.OnStep(7, "Checking for VSCode...", 0.0)
-	codeBin, err := vscode.FindCode()
-	if err != nil {
-		cfg.Logger.Log("VSCode not found: %v", err)
-		cfg.OnStep(7, "VSCode not found.", 1.0)
+	// Step 7: Check for VSCode and configure, or configure the editor
+	// chosen instead (Emacs also when it is the only editor found)
+	editorID := cfg.Editor
+	var codeEditor *vscode.Editor
+	if editorID == "" || editorID == workspace.EditorVSCode {
+		cfg.OnStep(7, "Checking for VSCode...", 0.0)
+		codeEditor, err = vscode.SelectEditor(vscode.FindEditors(), cfg.CodeEditor)
+		if err != nil {
+			cfg.Logger.Log("VSCode not found: %v", err)
+			if wsl.Detect() {
+				cfg.Logger.Log("WSL detected: install VSCode on Windows to use it here through Remote-WSL")
+			}
+			if _, emacsErr := emacs.FindEmacs(); editorID == "" && emacsErr == nil {
+				cfg.Logger.Log("Emacs found, configuring Proof General instead")
+				editorID = workspace.EditorEmacs
+			}
+		}
+	}
+	if codeEditor == nil {
+		var topPath string
+		env := &workspace.ActivationEnv{SwitchName: switchName, OpamRoot: cfg.OpamRoot, Path: []string{switchBinDir(runner, switchName)}}
+		switch editorID {
+		case workspace.EditorEmacs:
+			cfg.OnStep(7, "Configuring Emacs...", 0.0)
+			if err := configureEmacs(workspaceDir, env, cfg.CompanyCoq, cfg.Logger); err != nil {
+				cfg.Logger.Log("WARNING: Emacs not configured: %v", err)
+			} else {
+				result.Editor = editorID
+			}
+		case workspace.EditorNeovim:
+			cfg.OnStep(7, "Configuring Neovim...", 0.0)
+			topPath = findLanguageServerTop(runner, switchName, cfg.Manifest.RocqVersion)
+			if err := configureVim(workspaceDir, env, topPath, cfg.Logger); err != nil {
+				cfg.Logger.Log("WARNING: Neovim not configured: %v", err)
+			} else {
+				result.Editor = editorID
+			}
+		}
+		if err := writeLockFile(runner, cfg.Manifest, switchName, workspaceDir, nil, cfg.Logger); err != nil {
+			cfg.Logger.Log("WARNING: lock file not written: %v", err)
+		}
+		register(cfg.Manifest, workspaceDir, switchName, cfg.OpamRoot, topPath, "", "", cfg.Logger)
+		if editorID == "" || editorID == workspace.EditorVSCode {
+			cfg.OnStep(7, "VSCode not found.", 1.0)
+		} else {
+			cfg.OnStep(7, "Done!", 1.0)
+		}
 		result.VSCodeFound = false
 		return result, nil
 	}
 	result.VSCodeFound = true
 
-	cfg.Logger.Log("VSCode CLI: %s", codeBin)
+	codeBin := codeEditor.Bin
+	cfg.Logger.Log("%s CLI: %s (extensions from %s)", codeEditor.Label(), codeBin, codeEditor.Marketplace())
+	if cfg.Profile != "" {
+		cfg.Logger.Log("Using VSCode profile %q", cfg.Profile)
+	}
+	if codeEditor.Remote == vscode.RemoteWSL {
+		// The shim runs the editor CLI of the WSL side: the extension lands
+		// there and uses the language server of the switch by its Linux path.
+		cfg.Logger.Log("WSL: installing the extension in %s and opening the workspace through Remote-WSL", wslDistro())
+	}
 	extensionID := vscode.ExtensionIDForVersion(cfg.Manifest.RocqVersion)
-	if err := vscode.InstallExtension(codeBin, extensionID); err != nil {
-		cfg.Logger.Log("WARNING: extension install failed: %v", err)
+	fromVSIX, err := installVSIX(cfg, codeBin)
+	if err != nil {
+		cfg.Logger.Log("WARNING: %v, installing from %s instead", err, codeEditor.Marketplace())
 	}
+	switch {
+	case fromVSIX:
+		// The .vsix sets the extension version; a locked one that differs is
+		// reported below.
+	case lock != nil && lock.Editor != nil && lock.Editor.ExtensionVersion != "":
+		extensionID = lock.Editor.Extension
+		if err := vscode.InstallExtensionVersion(codeBin, cfg.Profile, extensionID, lock.Editor.ExtensionVersion); err != nil {
+			cfg.Logger.Log("WARNING: extension install failed: %v", err)
+		}
+	case cfg.Manifest.VSCodeExtension.Version != "":
+		installPinnedExtension(codeBin, cfg.Profile, extensionID, cfg.Manifest.VSCodeExtension.Version, cfg.Logger)
+	default:
+		if err := vscode.InstallExtension(codeBin, cfg.Profile, extensionID); err != nil {
+			cfg.Logger.Log("WARNING: extension install failed: %v", err)
+		}
+	}
+	disabled := resolveConflict(cfg, codeBin, extensionID)
 
 	// Write VSCode settings with language server path from the switch
-	topPath := findLanguageServerTop(switchName, cfg.Manifest.RocqVersion)
+	topPath := findLanguageServerTop(runner, switchName, cfg.Manifest.RocqVersion)
+	var userSettings string
 	if topPath != "" {
 		settingsKey := "vsrocq.path"
 		if vscode.IsCoq(cfg.Manifest.RocqVersion) {
 			settingsKey = "vscoq.path"
 		}
-		if err := workspace.WriteVSCodeSettings(workspaceDir, settingsKey, topPath); err != nil {
-			return nil, fmt.Errorf("vscode config: %w", err)
+		serverPath := languageServerSetting(workspaceDir, topPath, codeEditor, cfg.Logger)
+		userSettings = userSettingsFile(cfg, codeEditor)
+		if err := workspace.WriteScopedSettings(workspaceDir, userSettings, vscodeSettings(settingsKey, serverPath, cfg.OpamRoot)); err != nil {
+			cfg.Logger.Log("WARNING: VSCode settings not updated: %v", err)
+			userSettings = ""
+		} else if userSettings != "" {
+			cfg.Logger.Log("VSCode user settings %s written with %s=%s", userSettings, settingsKey, serverPath)
+		} else {
+			cfg.Logger.Log("VSCode settings written with %s=%s", settingsKey, serverPath)
 		}
-		cfg.Logger.Log("VSCode settings written with %s=%s", settingsKey, topPath)
 	}
 
-	cfg.Logger.Log("Opening VSCode with workspace %s", workspaceDir)
-	if err := vscode.OpenWorkspace(codeBin, workspaceDir); err != nil {
+	tasks, taskEnv := switchTasks(runner, workspaceDir, switchName, vscode.IsCoq(cfg.Manifest.RocqVersion))
+	writeProjectFiles(workspaceDir, extensionID, tasks, taskEnv, cfg.CodeWorkspace, cfg.Logger)
+
+	editor := &lockfile.Editor{Extension: extensionID, LanguageServer: topPath}
+	if version, err := vscode.InstalledExtensionVersion(codeBin, cfg.Profile, extensionID); err != nil {
+		cfg.Logger.Log("WARNING: could not get %s version: %v", extensionID, err)
+	} else {
+		editor.ExtensionVersion = version
+	}
+	if lock != nil && lock.Editor != nil && lock.Editor.ExtensionVersion != "" && editor.ExtensionVersion != lock.Editor.ExtensionVersion {
+		result.Differences = append(result.Differences, fmt.Sprintf("%s: %q installed, %s locked",
+			extensionID, editor.ExtensionVersion, lock.Editor.ExtensionVersion))
+	}
+	if err := writeLockFile(runner, cfg.Manifest, switchName, workspaceDir, editor, cfg.Logger); err != nil {
+		cfg.Logger.Log("WARNING: lock file not written: %v", err)
+	}
+	register(cfg.Manifest, workspaceDir, switchName, cfg.OpamRoot, topPath, cfg.Profile, userSettings, cfg.Logger)
+
+	openPath := workspaceDir
+	if cfg.CodeWorkspace {
+		openPath = workspace.CodeWorkspaceFile(workspaceDir)
+	}
+	cfg.Logger.Log("Opening VSCode with workspace %s", openPath)
+	if err := vscode.OpenWorkspace(codeBin, cfg.Profile, openPath, disabled...); err != nil {
 		cfg.Logger.Log("WARNING: failed to open VSCode: %v", err)
 	}
 	cfg.OnStep(7, "Done!", 1.0)
@@ -195,40 +465,275 @@ func Run(cfg *Config) (*Result, error) {
 	return result, nil
 }
 
-// ensureOpam checks for opam in PATH or installs it.
-func ensureOpam(logger *Logger) (string, error) {
-	path, err := exec.LookPath("opam")
+// resolveConflict handles the extension conflicting with extensionID when
+// it is installed: with cfg.UninstallConflicting it is uninstalled and
+// recorded for settings.RestoreExtensions; otherwise it is returned, to be
+// disabled in the window the installer opens. VSCode does not keep that
+// for the next windows of the workspace.
+func resolveConflict(cfg *Config, codeBin, extensionID string) []string {
+	conflict := vscode.ConflictingExtensionID(extensionID)
+	version, err := vscode.InstalledExtensionVersion(codeBin, cfg.Profile, conflict)
+	if err != nil {
+		cfg.Logger.Log("WARNING: could not check for %s: %v", conflict, err)
+		return nil
+	}
+	if version == "" {
+		return nil
+	}
+	if !cfg.UninstallConflicting {
+		cfg.Logger.Log("WARNING: %s %s conflicts with %s; disabled in the window opened now only, as VSCode does not keep it: use \"Disable (Workspace)\" in the Extensions view to keep it off in this workspace, or rerun with --uninstall-conflicting", conflict, version, extensionID)
+		return []string{conflict}
+	}
+	if err := vscode.UninstallExtension(codeBin, cfg.Profile, conflict); err != nil {
+		cfg.Logger.Log("WARNING: %v", err)
+		return []string{conflict}
+	}
+	cfg.Logger.Log("Uninstalled %s %s, which conflicts with %s (restore it with --restore-extensions)", conflict, version, extensionID)
+	if err := settings.RecordRemovedExtension(vscode.RemovedExtension{Editor: codeBin, Profile: cfg.Profile, ID: conflict, Version: version}); err != nil {
+		cfg.Logger.Log("WARNING: %s not recorded for restore: %v", conflict, err)
+	}
+	return nil
+}
+
+// userSettingsFile returns the user settings file of codeEditor, in
+// cfg.Profile, the language server path goes into with cfg.SettingsScope,
+// or "" for the workspace settings. It is called after the extension is
+// installed, which creates the profile.
+func userSettingsFile(cfg *Config, codeEditor *vscode.Editor) string {
+	if cfg.SettingsScope != workspace.ScopeUser {
+		return ""
+	}
+	path, err := codeEditor.UserSettingsPath(cfg.Profile)
+	if err != nil {
+		cfg.Logger.Log("WARNING: %v; writing the workspace settings instead", err)
+		return ""
+	}
+	return path
+}
+
+// wslDistro returns the name of the WSL distribution for messages.
+func wslDistro() string {
+	if name := wsl.Distro(); name != "" {
+		return name
+	}
+	return "the WSL distribution"
+}
+
+// installPinnedExtension installs the extension version pinned in the
+// manifest, replacing a mismatched installed one, or else the latest one.
+func installPinnedExtension(codeBin, profile, extensionID, version string, logger *Logger) {
+	previous, err := vscode.EnsureExtensionVersion(codeBin, profile, extensionID, version)
 	if err == nil {
-		// Verify version
-		out, err := exec.Command(path, "--version").Output()
-		if err == nil {
-			ver := strings.TrimSpace(string(out))
-			logger.Log("opam version: %s", ver)
-			if !strings.HasPrefix(ver, "2.") {
-				return "", fmt.Errorf("opam >= 2.x required (found %s)", ver)
-			}
+		if previous != version {
+			logger.Log("%s %s installed (was %q)", extensionID, version, previous)
+		}
+		return
+	}
+	logger.Log("WARNING: %s %s install failed, installing the latest version: %v", extensionID, version, err)
+	if err := vscode.InstallExtension(codeBin, profile, extensionID); err != nil {
+		logger.Log("WARNING: extension install failed: %v", err)
+	}
+}
+
+// installVSIX installs the extension from the local .vsix given in
+// cfg.VSIX, or else from the one pinned in the manifest, downloaded and
+// verified. It reports false when there is no .vsix to install from.
+func installVSIX(cfg *Config, codeBin string) (bool, error) {
+	path := cfg.VSIX
+	if path == "" {
+		vsix := cfg.Manifest.VSCodeExtension.VSIX
+		if vsix == nil || vsix.URL == "" {
+			return false, nil
+		}
+		tempDir, err := os.MkdirTemp("", "rocq-bootstrap-vsix-")
+		if err != nil {
+			return false, fmt.Errorf("create temp dir: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+		if path, err = sharedinstaller.FetchVSIX(vsix.URL, vsix.SHA256, tempDir, cfg.Logger); err != nil {
+			return false, err
+		}
+	}
+	cfg.Logger.Log("Installing the extension from %s", path)
+	if err := vscode.InstallVSIX(codeBin, cfg.Profile, path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// languageServerSetting returns the language server path to write in the
+// settings of codeEditor for topPath. An editor sandboxed by Flatpak gets
+// a wrapper running topPath on the host. A strictly confined snap only
+// reads the non-hidden files of the home directory, so a language server
+// elsewhere is reported.
+func languageServerSetting(workspaceDir, topPath string, codeEditor *vscode.Editor, logger *Logger) string {
+	switch codeEditor.Sandbox {
+	case vscode.SandboxFlatpak:
+		wrapper, err := workspace.WriteHostWrapper(workspaceDir, topPath)
+		if err != nil {
+			logger.Log("WARNING: %v, %s may not start the language server", err, codeEditor.Label())
+			return topPath
+		}
+		logger.Log("%s is sandboxed, the language server runs on the host through %s", codeEditor.Label(), wrapper)
+		return wrapper
+	case vscode.SandboxSnap:
+		if !snapReachable(topPath) {
+			logger.Log("WARNING: the %s snap cannot reach %s, use a project-local switch (--local-switch) or the classic snap", codeEditor.Name, topPath)
 		}
-		return path, nil
 	}
-	return "", fmt.Errorf("opam not found in PATH. Please install opam: https://opam.ocaml.org/doc/Install.html")
+	return topPath
 }
 
-// initOpam runs opam init if ~/.opam doesn't exist.
-func initOpam(logger *Logger) error {
+// snapReachable reports whether a strictly confined snap can read path:
+// such snaps only see the non-hidden files of the home directory.
+func snapReachable(path string) bool {
 	home, err := os.UserHomeDir()
 	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(home, path)
+	if err != nil {
+		return false
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// vscodeSettings returns the workspace settings pointing the extension at
+// the language server (dropping the setting of the other extension),
+// exporting OPAMROOT in the integrated terminal when the switch lives in a
+// dedicated opam root, and removing it otherwise (as when a workspace is
+// relinked to the default root).
+func vscodeSettings(key, topPath, opamRoot string) map[string]interface{} {
+	settings := map[string]interface{}{"vsrocq.path": nil, "vscoq.path": nil}
+	settings[key] = topPath
+	if opamRoot != "" {
+		settings["terminal.integrated.env.linux"] = map[string]string{"OPAMROOT": opamRoot}
+	} else {
+		settings["terminal.integrated.env.linux"] = map[string]interface{}{"OPAMROOT": nil}
+	}
+	return settings
+}
+
+// writeLockFile records what ended up in the switch (packages, compiler,
+// repositories) together with the release and editor setup in the
+// workspace lock file.
+func writeLockFile(runner *opam.Runner, m *manifest.Manifest, switchName, workspaceDir string, editor *lockfile.Editor, logger *Logger) error {
+	lock := lockfile.New(m.Channel, m.PlatformRelease, m.RocqVersion)
+	lock.Editor = editor
+	lock.Opam.Switch = switchName
+
+	var err error
+	if lock.Opam.Version, err = runner.Version(); err != nil {
+		return fmt.Errorf("opam version: %w", err)
+	}
+	if lock.Opam.Invariant, err = runner.Invariant(switchName); err != nil {
+		logger.Log("WARNING: could not get switch invariant: %v", err)
+	}
+	if lock.Opam.Repositories, err = runner.Repositories(switchName); err != nil {
+		return fmt.Errorf("opam repo list: %w", err)
+	}
+	if lock.Opam.Packages, err = runner.InstalledPackages(switchName); err != nil {
+		return fmt.Errorf("opam list: %w", err)
+	}
+	export, err := runner.Export(switchName)
+	if err != nil {
+		return fmt.Errorf("opam switch export: %w", err)
+	}
+	lock.Opam.SwitchExport = strings.Split(strings.TrimRight(export, "\n"), "\n")
+
+	if err := lockfile.Write(workspaceDir, lock); err != nil {
 		return err
 	}
+	logger.Log("Lock file written to %s", filepath.Join(workspaceDir, lockfile.FileName))
+	return nil
+}
 
-	opamDir := filepath.Join(home, ".opam")
+// ensureOpam returns an opam executable satisfying the manifest's minimum
+// version. It prefers the binary managed by rocq-bootstrap, then opam from
+// PATH, and otherwise downloads the pinned release (which is also how an
+// opam that is too old gets upgraded). A managed opam older than the pinned
+// release but not than the minimum is kept if the download fails.
+func ensureOpam(b *manifest.OpamBootstrap, logger *Logger, progress sharedinstaller.ProgressFunc) (string, error) {
+	minVersion := opam.MinVersion(b)
+	var usable, usableVersion string // managed opam to fall back on
+
+	managed, err := opam.ManagedBinary()
+	if err != nil {
+		return "", fmt.Errorf("get home dir: %w", err)
+	}
+	if _, err := os.Stat(managed); err == nil {
+		ver, err := (&opam.Runner{Bin: managed}).Version()
+		switch {
+		case err != nil:
+			logger.Log("WARNING: managed opam %s is not usable: %v", managed, err)
+		case opam.CompareVersions(ver, minVersion) < 0:
+			logger.Log("Managed opam %s is older than required %s, upgrading", ver, minVersion)
+		case b.Version != "" && opam.CompareVersions(ver, b.Version) < 0:
+			logger.Log("Managed opam %s is older than pinned %s, upgrading", ver, b.Version)
+			usable, usableVersion = managed, ver
+		default:
+			logger.Log("opam version: %s (managed)", ver)
+			return managed, nil
+		}
+	}
+
+	if path, err := exec.LookPath("opam"); err == nil {
+		ver, err := (&opam.Runner{Bin: path}).Version()
+		if err == nil {
+			logger.Log("opam version: %s", ver)
+			if opam.CompareVersions(ver, minVersion) >= 0 {
+				return path, nil
+			}
+			logger.Log("opam %s at %s is older than required %s, installing opam %s", ver, path, minVersion, b.Version)
+		} else {
+			logger.Log("WARNING: could not get version of %s: %v", path, err)
+		}
+	} else {
+		logger.Log("opam not found in PATH, installing opam %s", b.Version)
+	}
+
+	bin, err := opam.Download(b, logger, progress)
+	if err != nil && usable != "" {
+		logger.Log("WARNING: could not upgrade opam: %v; keeping managed opam %s", err, usableVersion)
+		return usable, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	ver, err := (&opam.Runner{Bin: bin}).Version()
+	if err != nil {
+		return "", fmt.Errorf("downloaded opam is not usable: %w", err)
+	}
+	if opam.CompareVersions(ver, minVersion) < 0 {
+		return "", fmt.Errorf("opam >= %s required (downloaded %s)", minVersion, ver)
+	}
+	logger.Log("opam version: %s (managed)", ver)
+	return bin, nil
+}
+
+// initOpam runs opam init if the runner's opam root doesn't exist.
+// A dedicated root is initialized without touching the user's shell setup.
+func initOpam(runner *opam.Runner, logger *Logger) error {
+	opamDir := runner.RootDir()
+	if opamDir == "" {
+		return fmt.Errorf("could not determine opam root")
+	}
 	if _, err := os.Stat(opamDir); err == nil {
 		logger.Log("opam already initialized (%s exists)", opamDir)
 		return nil
 	}
 
-	logger.Log("Running opam init...")
-	cmd := exec.Command("opam", "init", "-y", "--bare", "--disable-sandboxing")
-	cmd.Env = append(os.Environ(), "OPAMCONFIRMLEVEL=unsafe-yes")
+	logger.Log("Running opam init (root: %s)...", opamDir)
+	args := []string{"init", "-y", "--bare", "--disable-sandboxing"}
+	if runner.Root != "" {
+		args = append(args, "--no-setup")
+	}
+	cmd := runner.Command(args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("opam init failed: %w\nOutput: %s", err, string(output))
@@ -238,9 +743,10 @@ func initOpam(logger *Logger) error {
 }
 
 // createSwitch creates the opam switch if it doesn't already exist.
-func createSwitch(switchName, compiler string, logger *Logger) error {
+// An empty compiler creates an empty switch.
+func createSwitch(runner *opam.Runner, switchName, compiler string, logger *Logger) error {
 	// Check if switch already exists
-	out, err := exec.Command("opam", "switch", "list", "--short").Output()
+	out, err := runner.Command("switch", "list", "--short").Output()
 	if err == nil {
 		for _, line := range strings.Split(string(out), "\n") {
 			if strings.TrimSpace(line) == switchName {
@@ -251,8 +757,17 @@ func createSwitch(switchName, compiler string, logger *Logger) error {
 	}
 
 	logger.Log("Creating switch %s with compiler %s", switchName, compiler)
-	cmd := exec.Command("opam", "switch", "create", switchName, compiler, "-y")
-	cmd.Env = append(os.Environ(), "OPAMCONFIRMLEVEL=unsafe-yes")
+	args := []string{"switch", "create", switchName, "-y"}
+	if compiler != "" {
+		args = append(args, compiler)
+	} else {
+		args = append(args, "--empty")
+	}
+	if opam.IsLocalSwitch(switchName) {
+		// Don't install opam files the project directory may contain.
+		args = append(args, "--no-install")
+	}
+	cmd := runner.Command(args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("opam switch create failed: %w\nOutput: %s", err, string(output))
@@ -262,34 +777,30 @@ func createSwitch(switchName, compiler string, logger *Logger) error {
 }
 
 // configureRepo adds and configures the rocq-released repository for the switch.
-func configureRepo(switchName, repoName, repoURL string, logger *Logger) error {
+func configureRepo(runner *opam.Runner, switchName, repoName, repoURL string, logger *Logger) error {
 	logger.Log("Configuring repo %s -> %s (switch=%s)", repoName, repoURL, switchName)
 
 	// Add repo (ignore error if already exists)
-	cmd := exec.Command("opam", "repo", "add", "--switch="+switchName, repoName, repoURL, "-y")
-	cmd.Env = append(os.Environ(), "OPAMCONFIRMLEVEL=unsafe-yes")
+	cmd := runner.Command("repo", "add", "--switch="+switchName, repoName, repoURL, "-y")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		// Repo might already exist, try set-url
 		logger.Log("repo add failed (may exist), trying set-url: %s", string(output))
-		cmd2 := exec.Command("opam", "repo", "set-url", "--switch="+switchName, repoName, repoURL, "-y")
-		cmd2.Env = append(os.Environ(), "OPAMCONFIRMLEVEL=unsafe-yes")
+		cmd2 := runner.Command("repo", "set-url", "--switch="+switchName, repoName, repoURL, "-y")
 		if out2, err2 := cmd2.CombinedOutput(); err2 != nil {
 			return fmt.Errorf("repo set-url failed: %w\nOutput: %s", err2, string(out2))
 		}
 	}
 
 	// Set repo priority
-	cmd = exec.Command("opam", "repo", "priority", "--switch="+switchName, repoName, "1")
-	cmd.Env = append(os.Environ(), "OPAMCONFIRMLEVEL=unsafe-yes")
+	cmd = runner.Command("repo", "priority", "--switch="+switchName, repoName, "1")
 	if output, err := cmd.CombinedOutput(); err != nil {
 		logger.Log("WARNING: repo priority failed: %s", string(output))
 	}
 
 	// Update
 	logger.Log("Updating opam repos...")
-	cmd = exec.Command("opam", "update", "--switch="+switchName)
-	cmd.Env = append(os.Environ(), "OPAMCONFIRMLEVEL=unsafe-yes")
+	cmd = runner.Command("update", "--switch="+switchName)
 	if output, err = cmd.CombinedOutput(); err != nil {
 		logger.Log("WARNING: opam update failed: %s", string(output))
 	}
@@ -298,7 +809,7 @@ func configureRepo(switchName, repoName, repoURL string, logger *Logger) error {
 }
 
 // installPackages installs the Rocq packages into the switch.
-func installPackages(switchName string, packages []manifest.OpamPackage, logger *Logger, onProgress func(float64)) error {
+func installPackages(runner *opam.Runner, switchName string, packages []manifest.OpamPackage, logger *Logger, onProgress func(float64)) error {
 	// Build package list (skip optional packages with "with_rocqide" flag)
 	var pkgs []string
 	for _, pkg := range packages {
@@ -314,9 +825,94 @@ func installPackages(switchName string, packages []manifest.OpamPackage, logger
 	args := []string{"install", "--switch=" + switchName, "-y"}
 	args = append(args, pkgs...)
 
-	cmd := exec.Command("opam", args...)
-	cmd.Env = append(os.Environ(), "OPAMCONFIRMLEVEL=unsafe-yes")
+	if err := runWithProgress(runner.Command(args...), logger, onProgress); err != nil {
+		return fmt.Errorf("opam install failed: %w", err)
+	}
+	return nil
+}
+
+// importSwitch installs the packages recorded in a switch export (one line
+// per entry, as stored in the lock file) into the switch.
+func importSwitch(runner *opam.Runner, switchName string, export []string, logger *Logger, onProgress func(float64)) error {
+	f, err := os.CreateTemp("", "rocq-lock-*.export")
+	if err != nil {
+		return fmt.Errorf("create export file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(strings.Join(export, "\n") + "\n"); err != nil {
+		f.Close()
+		return fmt.Errorf("write export file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write export file: %w", err)
+	}
+
+	logger.Log("Importing switch export into %s", switchName)
+	cmd := runner.Command("switch", "import", f.Name(), "--switch="+switchName, "-y")
+	if err := runWithProgress(cmd, logger, onProgress); err != nil {
+		return fmt.Errorf("opam switch import failed: %w", err)
+	}
+	return nil
+}
+
+// reproducePackages installs the packages of a lock file one at a time,
+// compiler first: each at its locked version, or else at the nearest
+// available one. Packages already installed at their locked version are
+// skipped. What is not reproduced exactly shows in lockfile.Diff.
+func reproducePackages(runner *opam.Runner, switchName string, locked lockfile.Opam, logger *Logger, onProgress func(float64)) {
+	have := make(map[string]string)
+	if installed, err := runner.InstalledPackages(switchName); err == nil {
+		for _, p := range installed {
+			have[p.Name] = p.Version
+		}
+	}
+	// The packages of the invariant (["ocaml-base-compiler" {= "4.14.2"}])
+	// must not be chosen by opam for the others.
+	var pkgs, others []opam.Package
+	for _, p := range locked.Packages {
+		if strings.Contains(locked.Invariant, `"`+p.Name+`"`) {
+			pkgs = append(pkgs, p)
+		} else {
+			others = append(others, p)
+		}
+	}
+	pkgs = append(pkgs, others...)
 
+	for i, p := range pkgs {
+		onProgress(float64(i) / float64(len(pkgs)))
+		if have[p.Name] == p.Version {
+			continue
+		}
+		err := installVersion(runner, switchName, p.Name, p.Version, logger)
+		if err == nil {
+			continue
+		}
+		logger.Log("WARNING: %s.%s could not be installed: %v", p.Name, p.Version, err)
+		versions, err := runner.AvailableVersions(switchName, p.Name)
+		if err != nil {
+			logger.Log("WARNING: could not list the versions of %s: %v", p.Name, err)
+			continue
+		}
+		nearest := opam.NearestVersion(versions, p.Version)
+		if nearest == "" || nearest == p.Version {
+			continue
+		}
+		logger.Log("Installing %s.%s instead", p.Name, nearest)
+		if err := installVersion(runner, switchName, p.Name, nearest, logger); err != nil {
+			logger.Log("WARNING: %s.%s could not be installed: %v", p.Name, nearest, err)
+		}
+	}
+}
+
+// installVersion installs one version of a package into the switch.
+func installVersion(runner *opam.Runner, switchName, name, version string, logger *Logger) error {
+	logger.Log("Installing %s.%s into %s", name, version, switchName)
+	return runWithProgress(runner.Command("install", "--switch="+switchName, name+"."+version, "-y"), logger, func(float64) {})
+}
+
+// runWithProgress runs a long opam command, logging its output and
+// estimating progress from it.
+func runWithProgress(cmd *exec.Cmd, logger *Logger, onProgress func(float64)) error {
 	// Capture stdout for progress
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -325,7 +921,7 @@ func installPackages(switchName string, packages []manifest.OpamPackage, logger
 	cmd.Stderr = cmd.Stdout // merge stderr into stdout
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("start opam install: %w", err)
+		return fmt.Errorf("start: %w", err)
 	}
 
 	// Parse output for progress
@@ -344,20 +940,15 @@ func installPackages(switchName string, packages []manifest.OpamPackage, logger
 		onProgress(fraction)
 	}
 
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("opam install failed: %w", err)
-	}
-
-	return nil
+	return cmd.Wait()
 }
 
 // findLanguageServerTop locates the vsrocqtop or vscoqtop binary in the opam switch.
-func findLanguageServerTop(switchName, rocqVersion string) string {
-	out, err := exec.Command("opam", "var", "--switch="+switchName, "bin").Output()
-	if err != nil {
+func findLanguageServerTop(runner *opam.Runner, switchName, rocqVersion string) string {
+	binDir := switchBinDir(runner, switchName)
+	if binDir == "" {
 		return ""
 	}
-	binDir := strings.TrimSpace(string(out))
 
 	binName := "vsrocqtop"
 	if vscode.IsCoq(rocqVersion) {
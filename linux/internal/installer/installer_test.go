@@ -0,0 +1,39 @@
+package installer
+
+import (
+	"testing"
+
+	"github.com/justme0606/rocq-bootstrap/linux/internal/workspace"
+)
+
+// TestVSCodeSettingsRelinkDefaultRoot checks that relinking a workspace
+// from a dedicated opam root to the default one drops OPAMROOT from the
+// terminal environment, and that the default root never writes it.
+func TestVSCodeSettingsRelinkDefaultRoot(t *testing.T) {
+	const env = "terminal.integrated.env.linux"
+
+	dir := t.TempDir()
+	if err := workspace.WriteVSCodeSettings(dir, vscodeSettings("vsrocq.path", "/bin/vsrocqtop", "")); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := workspace.ReadVSCodeSettings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := settings[env]; ok {
+		t.Errorf("%s = %v with the default root, want it unset", env, v)
+	}
+
+	if err := workspace.WriteVSCodeSettings(dir, vscodeSettings("vsrocq.path", "/bin/vsrocqtop", "/home/me/.rocq-setup/opam")); err != nil {
+		t.Fatal(err)
+	}
+	if err := workspace.WriteVSCodeSettings(dir, vscodeSettings("vsrocq.path", "/bin/vsrocqtop", "")); err != nil {
+		t.Fatal(err)
+	}
+	if settings, err = workspace.ReadVSCodeSettings(dir); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := settings[env].(map[string]interface{})["OPAMROOT"]; ok {
+		t.Errorf("OPAMROOT = %v after relinking to the default root, want it unset", v)
+	}
+}
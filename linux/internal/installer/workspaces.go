@@ -0,0 +1,229 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/justme0606/rocq-bootstrap/linux/internal/emacs"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/manifest"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/opam"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/vscode"
+	"github.com/justme0606/rocq-bootstrap/linux/internal/workspace"
+	"github.com/justme0606/rocq-bootstrap/shared/registry"
+)
+
+// register records the workspace and the switch it is bound to in the
+// workspace registry.
+func register(m *manifest.Manifest, workspaceDir, switchName, opamRoot, topPath, profile, userSettings string, logger *Logger) {
+	err := registry.Register(&registry.Entry{
+		Dir:             workspaceDir,
+		PlatformRelease: m.PlatformRelease,
+		RocqVersion:     m.RocqVersion,
+		Switch:          switchName,
+		OpamRoot:        opamRoot,
+		LanguageServer:  topPath,
+		Profile:         profile,
+		UserSettings:    userSettings,
+	})
+	if err != nil {
+		logger.Log("WARNING: could not register workspace: %v", err)
+	}
+}
+
+// switchTasks returns the workspace build tasks, run in the switch through
+// opam exec, and the environment they need.
+func switchTasks(runner *opam.Runner, workspaceDir, switchName string, coq bool) ([]workspace.Task, map[string]string) {
+	tasks := workspace.RunThrough(workspace.BuildTasks(workspaceDir, coq), runner.Bin, "exec", "--switch="+switchName, "--")
+	if runner.Root == "" {
+		return tasks, nil
+	}
+	return tasks, map[string]string{"OPAMROOT": runner.Root}
+}
+
+// setUpBuild writes the files of the build system into the workspace and
+// checks that the workspace builds in the switch.
+func setUpBuild(runner *opam.Runner, workspaceDir, switchName, system string, coq bool, logger *Logger) error {
+	if err := workspace.WriteBuildFiles(workspaceDir, system, coq); err != nil {
+		return err
+	}
+	logger.Log("Building workspace with %s", system)
+	tasks, env := switchTasks(runner, workspaceDir, switchName, coq)
+	if err := workspace.RunBuild(workspaceDir, tasks, env); err != nil {
+		return err
+	}
+	logger.Log("Workspace build OK")
+	return nil
+}
+
+// initGit initializes the workspace as a git repository. Failures are
+// only logged: the workspace is usable without git.
+func initGit(workspaceDir string, logger *Logger) {
+	if err := workspace.InitGit(workspaceDir); err != nil {
+		logger.Log("WARNING: git repository not initialized: %v", err)
+		return
+	}
+	logger.Log("Git repository ready in %s", workspaceDir)
+}
+
+// writeProjectFiles writes the VSCode build tasks and extension
+// recommendations and, when requested, the .code-workspace file.
+func writeProjectFiles(workspaceDir, extensionID string, tasks []workspace.Task, env map[string]string, codeWorkspace bool, logger *Logger) {
+	if err := workspace.WriteVSCodeTasks(workspaceDir, tasks, env); err != nil {
+		logger.Log("WARNING: VSCode tasks not written: %v", err)
+	}
+	if err := workspace.WriteVSCodeExtensions(workspaceDir, extensionID, vscode.ConflictingExtensionID(extensionID)); err != nil {
+		logger.Log("WARNING: VSCode extension recommendations not written: %v", err)
+	}
+	if codeWorkspace {
+		if err := workspace.WriteCodeWorkspace(workspaceDir); err != nil {
+			logger.Log("WARNING: .code-workspace file not written: %v", err)
+		}
+	}
+}
+
+// configureEmacs installs Proof General (and company-coq) when Emacs is
+// found, and writes the Emacs file of the workspace pointing it at the
+// binaries of env.
+func configureEmacs(workspaceDir string, env *workspace.ActivationEnv, companyCoq bool, logger *Logger) error {
+	if emacsBin, err := emacs.FindEmacs(); err != nil {
+		logger.Log("WARNING: %v, Emacs packages not installed", err)
+	} else {
+		logger.Log("Emacs: %s", emacsBin)
+		packages := []string{emacs.ProofGeneral}
+		if companyCoq {
+			packages = append(packages, emacs.CompanyCoq)
+		}
+		for _, pkg := range packages {
+			if err := emacs.InstallPackage(emacsBin, pkg); err != nil {
+				logger.Log("WARNING: %s install failed: %v", pkg, err)
+			}
+		}
+	}
+
+	if err := workspace.WriteEmacsConfig(workspaceDir, env, companyCoq); err != nil {
+		return err
+	}
+	logger.Log("Emacs configuration written, load it from your init file with %s", workspace.EmacsLoadForm(workspaceDir))
+	return nil
+}
+
+// configureVim writes the Neovim and Vim files of the workspace, pointing
+// them at the binaries of env and the language server topPath.
+func configureVim(workspaceDir string, env *workspace.ActivationEnv, topPath string, logger *Logger) error {
+	if topPath == "" {
+		logger.Log("WARNING: no language server in the switch, Neovim will only have Coqtail")
+	}
+	if err := workspace.WriteVimConfig(workspaceDir, env, topPath); err != nil {
+		return err
+	}
+	logger.Log("Neovim/Vim configuration written, %s", workspace.SetupHint(workspace.EditorNeovim, workspaceDir))
+	return nil
+}
+
+// Relink binds the registered workspace dir to the installation with the
+// given label (see Installation.Label): it rewrites the activation scripts
+// and the VSCode settings (and the Flatpak host wrapper, Emacs, Neovim and
+// Vim files, if any), then updates the registry.
+func Relink(dir, label string) (*registry.Entry, error) {
+	reg, err := registry.Load()
+	if err != nil {
+		return nil, err
+	}
+	entry := reg.Find(dir)
+	if entry == nil {
+		return nil, fmt.Errorf("%s is not a registered workspace", dir)
+	}
+	inst, ok := LookupInstallation(label)
+	if !ok {
+		return nil, fmt.Errorf("opam switch %s not found", label)
+	}
+	root := inst.Root
+	if root == opam.DefaultRoot() {
+		root = ""
+	}
+	runner := &opam.Runner{Bin: opam.Locate(), Root: root}
+
+	activation := &workspace.ActivationEnv{SwitchName: inst.Switch, OpamRoot: root}
+	if opam.IsManaged(runner.Bin) {
+		activation.OpamBin = runner.Bin
+	}
+	if err := workspace.WriteActivationScripts(entry.Dir, activation); err != nil {
+		return nil, fmt.Errorf("activation scripts: %w", err)
+	}
+
+	topPath, settingsKey := languageServerIn(runner, inst.Switch)
+	if topPath != "" {
+		// A workspace set up for a Flatpak editor keeps running the language
+		// server on the host.
+		serverPath := topPath
+		if workspace.HasHostWrapper(entry.Dir) {
+			if serverPath, err = workspace.WriteHostWrapper(entry.Dir, topPath); err != nil {
+				return nil, fmt.Errorf("host wrapper: %w", err)
+			}
+		}
+		if err := workspace.WriteScopedSettings(entry.Dir, entry.UserSettings, vscodeSettings(settingsKey, serverPath, root)); err != nil {
+			return nil, fmt.Errorf("vscode config: %w", err)
+		}
+	}
+	tasks, env := switchTasks(runner, entry.Dir, inst.Switch, settingsKey == "vscoq.path")
+	if err := workspace.WriteVSCodeTasks(entry.Dir, tasks, env); err != nil {
+		return nil, fmt.Errorf("vscode tasks: %w", err)
+	}
+	editorEnv := &workspace.ActivationEnv{SwitchName: inst.Switch, OpamRoot: root, Path: []string{switchBinDir(runner, inst.Switch)}}
+	if ec, err := workspace.ReadEmacsConfig(entry.Dir); err == nil {
+		if err := workspace.WriteEmacsConfig(entry.Dir, editorEnv, ec.CompanyCoq); err != nil {
+			return nil, fmt.Errorf("emacs config: %w", err)
+		}
+	}
+	if workspace.HasVimConfig(entry.Dir) {
+		if err := workspace.WriteVimConfig(entry.Dir, editorEnv, topPath); err != nil {
+			return nil, fmt.Errorf("vim config: %w", err)
+		}
+	}
+
+	release, rocqShort := ParseSwitchName(inst.Switch)
+	relinked := &registry.Entry{
+		Dir:             entry.Dir,
+		PlatformRelease: release,
+		RocqVersion:     rocqShort,
+		Switch:          inst.Switch,
+		OpamRoot:        root,
+		LanguageServer:  topPath,
+		Profile:         entry.Profile,
+		UserSettings:    entry.UserSettings,
+	}
+	reg.Put(relinked)
+	if err := reg.Save(); err != nil {
+		return nil, err
+	}
+	return relinked, nil
+}
+
+// languageServerIn returns the language server installed in the switch and
+// the VSCode setting naming it, or empty strings.
+func languageServerIn(runner *opam.Runner, switchName string) (topPath, settingsKey string) {
+	binDir := switchBinDir(runner, switchName)
+	if binDir == "" {
+		return "", ""
+	}
+
+	for _, key := range []string{"vsrocq", "vscoq"} {
+		p := filepath.Join(binDir, key+"top")
+		if _, err := os.Stat(p); err == nil {
+			return p, key + ".path"
+		}
+	}
+	return "", ""
+}
+
+// switchBinDir returns the directory holding the binaries of the switch,
+// or "" if opam cannot tell.
+func switchBinDir(runner *opam.Runner, switchName string) string {
+	out, err := runner.Command("var", "--switch="+switchName, "bin").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,117 @@
+package lockfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/justme0606/rocq-bootstrap/linux/internal/opam"
+)
+
+// FileName is the name of the lock file written into the workspace.
+const FileName = "rocq-lock.json"
+
+// formatVersion is bumped whenever the lock file layout changes incompatibly.
+const formatVersion = 1
+
+// Opam records the opam side of the environment.
+type Opam struct {
+	Version      string            `json:"version"`
+	Switch       string            `json:"switch"`
+	Invariant    string            `json:"invariant"`
+	Repositories []opam.Repository `json:"repositories"`
+	Packages     []opam.Package    `json:"packages"`
+	// SwitchExport holds the output of `opam switch export`, one line per
+	// entry so the file stays diffable.
+	SwitchExport []string `json:"switch_export"`
+}
+
+// Editor records the editor integration configured for the workspace.
+type Editor struct {
+	Extension        string `json:"extension"`
+	ExtensionVersion string `json:"extension_version,omitempty"`
+	LanguageServer   string `json:"language_server,omitempty"`
+}
+
+// Lock describes an installed Rocq environment.
+type Lock struct {
+	FormatVersion   int     `json:"format_version"`
+	Channel         string  `json:"channel"`
+	PlatformRelease string  `json:"platform_release"`
+	RocqVersion     string  `json:"rocq_version"`
+	Opam            Opam    `json:"opam"`
+	Editor          *Editor `json:"editor,omitempty"`
+}
+
+// New returns an empty lock for the given release.
+func New(channel, platformRelease, rocqVersion string) *Lock {
+	return &Lock{
+		FormatVersion:   formatVersion,
+		Channel:         channel,
+		PlatformRelease: platformRelease,
+		RocqVersion:     rocqVersion,
+	}
+}
+
+// Read loads a lock file.
+func Read(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read lock file: %w", err)
+	}
+	var l Lock
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("parse lock file: %w", err)
+	}
+	if l.FormatVersion > formatVersion {
+		return nil, fmt.Errorf("lock file format %d is newer than supported (%d), please update rocq-bootstrap", l.FormatVersion, formatVersion)
+	}
+	if len(l.Opam.SwitchExport) == 0 {
+		return nil, fmt.Errorf("lock file has no opam switch export")
+	}
+	return &l, nil
+}
+
+// Diff compares the packages recorded in a lock file with the ones actually
+// installed and describes every package that was not reproduced exactly.
+func Diff(locked, installed []opam.Package) []string {
+	have := make(map[string]string, len(installed))
+	for _, p := range installed {
+		have[p.Name] = p.Version
+	}
+	want := make(map[string]bool, len(locked))
+
+	var diffs []string
+	for _, p := range locked {
+		want[p.Name] = true
+		version, ok := have[p.Name]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s.%s: not installed", p.Name, p.Version))
+		case version != p.Version:
+			diffs = append(diffs, fmt.Sprintf("%s: %s installed, %s locked", p.Name, version, p.Version))
+		}
+	}
+	for _, p := range installed {
+		if !want[p.Name] {
+			diffs = append(diffs, fmt.Sprintf("%s.%s: installed but not locked", p.Name, p.Version))
+		}
+	}
+	return diffs
+}
+
+// Write writes the lock file into dir as indented JSON.
+func Write(dir string, l *Lock) error {
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode lock file: %w", err)
+	}
+	content = append(content, '\n')
+
+	dest := filepath.Join(dir, FileName)
+	if err := os.WriteFile(dest, content, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", dest, err)
+	}
+	return nil
+}
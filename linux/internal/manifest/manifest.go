@@ -14,12 +14,27 @@ type OpamPackage struct {
 	Optional string `json:"optional,omitempty"`
 }
 
+// OpamBinary is a prebuilt opam executable for one architecture.
+type OpamBinary struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// OpamBootstrap pins the opam release fetched when opam is missing or too old.
+// Binaries is keyed by architecture ("x86_64", "arm64").
+type OpamBootstrap struct {
+	MinVersion string                `json:"min_version"`
+	Version    string                `json:"version"`
+	Binaries   map[string]OpamBinary `json:"binaries"`
+}
+
 type OpamConfig struct {
 	OCamlCompiler string        `json:"ocaml_compiler"`
 	SwitchPrefix  string        `json:"switch_prefix"`
 	RepoName      string        `json:"repo_name"`
 	RepoURL       string        `json:"repo_url"`
 	Packages      []OpamPackage `json:"packages"`
+	Bootstrap     OpamBootstrap `json:"opam_bootstrap"`
 }
 
 type Asset struct {
@@ -38,6 +53,8 @@ type Manifest struct {
 	RocqVersion     string `json:"rocq_version"`
 	PlatformRelease string `json:"platform_release"`
 	Assets          Assets `json:"assets"`
+
+	VSCodeExtension sharedmanifest.VSCodeExtension `json:"vscode_extension"`
 }
 
 // Parse parses a manifest from raw JSON bytes.
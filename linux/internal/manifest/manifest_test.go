@@ -0,0 +1,39 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestBootstrapChecksums checks that every opam binary the shipped
+// manifests pin has a checksum: opam.Download refuses one without, so the
+// bootstrap would never run. Fill them in with
+// scripts/make-manifest.sh --compute-sha256.
+func TestBootstrapChecksums(t *testing.T) {
+	for _, path := range []string{
+		filepath.Join("..", "..", "..", "manifest", "latest.json"),
+		filepath.Join("..", "..", "embedded", "manifest", "latest.json"),
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := Parse(data)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		b := m.Assets.Linux.X86_64.Opam.Bootstrap
+		if len(b.Binaries) == 0 {
+			t.Errorf("%s: no opam binary pinned", path)
+		}
+		for arch, bin := range b.Binaries {
+			if bin.URL == "" {
+				t.Errorf("%s: opam %s binary has no URL", path, arch)
+			}
+			if bin.SHA256 == "" {
+				t.Errorf("%s: opam %s binary %s has no sha256", path, arch, bin.URL)
+			}
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package opam
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	sharedinstaller "github.com/justme0606/rocq-bootstrap/shared/installer"
+
+	"github.com/justme0606/rocq-bootstrap/linux/internal/manifest"
+)
+
+// fallbackMinVersion is used when the manifest does not define a minimum.
+const fallbackMinVersion = "2.0.0"
+
+// Package is an opam package at a given version.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Repository is an opam package repository.
+type Repository struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Runner runs opam commands with a fixed executable and opam root.
+type Runner struct {
+	Bin  string // opam executable (absolute path or "opam")
+	Root string // opam root; empty means opam's default (see DefaultRoot)
+}
+
+// Command returns an exec.Cmd running opam with the given arguments.
+// Confirmation prompts are disabled so the command never blocks on stdin.
+func (r *Runner) Command(args ...string) *exec.Cmd {
+	cmd := exec.Command(r.Bin, args...)
+	cmd.Env = append(os.Environ(), "OPAMCONFIRMLEVEL=unsafe-yes")
+	if r.Root != "" {
+		cmd.Env = append(cmd.Env, "OPAMROOT="+r.Root)
+	}
+	return cmd
+}
+
+// RootDir returns the opam root the runner operates on.
+func (r *Runner) RootDir() string {
+	if r.Root != "" {
+		return r.Root
+	}
+	return DefaultRoot()
+}
+
+// Version returns the version reported by `opam --version`.
+func (r *Runner) Version() (string, error) {
+	out, err := exec.Command(r.Bin, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Invariant returns the switch invariant (the compiler constraint).
+func (r *Runner) Invariant(switchName string) (string, error) {
+	out, err := r.Command("switch", "invariant", "--switch="+switchName).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Export returns the output of `opam switch export` for the switch.
+func (r *Runner) Export(switchName string) (string, error) {
+	out, err := r.Command("switch", "export", "--switch="+switchName, "-").Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// InstalledPackages returns the name and version of every package installed
+// in the switch, in opam's order.
+func (r *Runner) InstalledPackages(switchName string) ([]Package, error) {
+	out, err := r.Command("list", "--switch="+switchName, "--installed",
+		"--columns=name,installed-version", "--color=never").Output()
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []Package
+	for _, fields := range tableRows(string(out)) {
+		if len(fields) >= 2 {
+			pkgs = append(pkgs, Package{Name: fields[0], Version: fields[1]})
+		}
+	}
+	return pkgs, nil
+}
+
+// AvailableVersions returns the versions of the package that can be
+// installed in the switch, in opam's order.
+func (r *Runner) AvailableVersions(switchName, name string) ([]string, error) {
+	out, err := r.Command("list", "--switch="+switchName, "--available", "--all-versions",
+		"--columns=version", "--color=never", name).Output()
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, fields := range tableRows(string(out)) {
+		if len(fields) >= 1 {
+			versions = append(versions, fields[0])
+		}
+	}
+	return versions, nil
+}
+
+// Repositories returns the name and URL of the repositories configured for
+// the switch, highest priority first.
+func (r *Runner) Repositories(switchName string) ([]Repository, error) {
+	out, err := r.Command("repo", "list", "--switch="+switchName, "--color=never").Output()
+	if err != nil {
+		return nil, err
+	}
+	var repos []Repository
+	for _, fields := range tableRows(string(out)) {
+		// Rows are "<rank> <name> <url>".
+		if len(fields) >= 3 {
+			repos = append(repos, Repository{Name: fields[1], URL: fields[2]})
+		}
+	}
+	return repos, nil
+}
+
+// tableRows splits opam's tabular output into fields, skipping the
+// "#"-prefixed header lines.
+func tableRows(out string) [][]string {
+	var rows [][]string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rows = append(rows, strings.Fields(line))
+	}
+	return rows
+}
+
+// ManagedBinDir returns the directory holding the opam binary managed by
+// rocq-bootstrap (~/.rocq-setup/bin).
+func ManagedBinDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".rocq-setup", "bin"), nil
+}
+
+// ManagedBinary returns the path of the opam binary managed by rocq-bootstrap.
+func ManagedBinary() (string, error) {
+	dir, err := ManagedBinDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "opam"), nil
+}
+
+// DefaultRoot returns the opam root used when none is given: $OPAMROOT if
+// set, otherwise ~/.opam.
+func DefaultRoot() string {
+	if root := os.Getenv("OPAMROOT"); root != "" {
+		return root
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".opam")
+}
+
+// IsolatedRoot returns the dedicated opam root used by rocq-bootstrap
+// installs that must not share the user's own opam root (~/.rocq-setup/opam).
+func IsolatedRoot() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".rocq-setup", "opam")
+}
+
+// IsRoot reports whether dir is an initialized opam root.
+func IsRoot(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "config"))
+	return err == nil && !info.IsDir()
+}
+
+// KnownRoots returns the initialized opam roots rocq-bootstrap knows about:
+// the default root and the isolated root, plus any extra roots given.
+func KnownRoots(extra ...string) []string {
+	var roots []string
+	seen := make(map[string]bool)
+	for _, dir := range append([]string{DefaultRoot(), IsolatedRoot()}, extra...) {
+		if dir == "" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if IsRoot(dir) {
+			roots = append(roots, dir)
+		}
+	}
+	return roots
+}
+
+// IsLocalSwitch reports whether a switch name as printed by
+// `opam switch list` designates a project-local switch. opam names local
+// switches by the absolute path of the directory holding their _opam.
+func IsLocalSwitch(name string) bool {
+	return filepath.IsAbs(name)
+}
+
+// IsPlatformSwitch reports whether a switch looks like a Rocq/Coq Platform
+// install: a CP.* or coq-* named switch, or a local switch providing rocq or
+// coqc.
+func IsPlatformSwitch(name string) bool {
+	if strings.HasPrefix(name, "CP.") || strings.HasPrefix(name, "coq-") {
+		return true
+	}
+	if !IsLocalSwitch(name) {
+		return false
+	}
+	for _, bin := range []string{"rocq", "coqc"} {
+		if _, err := os.Stat(filepath.Join(name, "_opam", "bin", bin)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// IsManaged reports whether bin is the opam binary managed by rocq-bootstrap.
+func IsManaged(bin string) bool {
+	managed, err := ManagedBinary()
+	return err == nil && bin == managed
+}
+
+// Locate returns the opam executable to use: the managed binary if present,
+// otherwise opam from PATH. Returns "" if neither is available.
+func Locate() string {
+	if managed, err := ManagedBinary(); err == nil {
+		if info, err := os.Stat(managed); err == nil && !info.IsDir() {
+			return managed
+		}
+	}
+	if path, err := exec.LookPath("opam"); err == nil {
+		return path
+	}
+	return ""
+}
+
+// Arch returns the manifest architecture key for the running system.
+func Arch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	default:
+		return runtime.GOARCH
+	}
+}
+
+// MinVersion returns the minimum opam version required by the manifest.
+func MinVersion(b *manifest.OpamBootstrap) string {
+	if b.MinVersion != "" {
+		return b.MinVersion
+	}
+	return fallbackMinVersion
+}
+
+// CompareVersions compares two opam version strings numerically component by
+// component ("2.1.5" < "2.3.0"). Pre-release suffixes ("~rc1") are ignored.
+func CompareVersions(a, b string) int {
+	ap := versionParts(a)
+	bp := versionParts(b)
+	for i := 0; i < len(ap) || i < len(bp); i++ {
+		var x, y int
+		if i < len(ap) {
+			x = ap[i]
+		}
+		if i < len(bp) {
+			y = bp[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// NearestVersion returns the version of versions closest to want: the
+// highest one not above it, or else the lowest one above it. Returns ""
+// when versions is empty.
+func NearestVersion(versions []string, want string) string {
+	var below, above string
+	for _, v := range versions {
+		if CompareVersions(v, want) <= 0 {
+			if below == "" || CompareVersions(v, below) > 0 {
+				below = v
+			}
+		} else if above == "" || CompareVersions(v, above) < 0 {
+			above = v
+		}
+	}
+	if below != "" {
+		return below
+	}
+	return above
+}
+
+func versionParts(v string) []int {
+	if i := strings.IndexAny(v, "~-+"); i >= 0 {
+		v = v[:i]
+	}
+	var nums []int
+	for _, p := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(p)
+		nums = append(nums, n)
+	}
+	return nums
+}
+
+// Download fetches the opam binary pinned in the manifest for the running
+// architecture, verifies its checksum and installs it as the managed binary,
+// replacing any older managed copy. Returns the path to the installed binary.
+func Download(b *manifest.OpamBootstrap, logger *sharedinstaller.Logger, progress sharedinstaller.ProgressFunc) (string, error) {
+	arch := Arch()
+	bin, ok := b.Binaries[arch]
+	if !ok || bin.URL == "" {
+		return "", fmt.Errorf("no opam binary pinned in manifest for architecture %s. Please install opam: https://opam.ocaml.org/doc/Install.html", arch)
+	}
+	// VerifySHA256 accepts any file when no checksum is given: never run
+	// an unverified binary.
+	if strings.TrimSpace(bin.SHA256) == "" {
+		return "", fmt.Errorf("no sha256 pinned in manifest for the opam %s binary. Please install opam: https://opam.ocaml.org/doc/Install.html", arch)
+	}
+
+	dest, err := ManagedBinary()
+	if err != nil {
+		return "", fmt.Errorf("get home dir: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "rocq-bootstrap-opam-")
+	if err != nil {
+		return "", fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	logger.Log("Downloading opam %s (%s) from %s", b.Version, arch, bin.URL)
+	path, err := sharedinstaller.Download(bin.URL, tempDir, "opam", progress)
+	if err != nil {
+		return "", fmt.Errorf("download opam: %w", err)
+	}
+
+	logger.Log("Verifying opam SHA256 (expected: %q)", bin.SHA256)
+	if err := sharedinstaller.VerifySHA256(path, bin.SHA256); err != nil {
+		return "", fmt.Errorf("opam checksum: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", fmt.Errorf("create %s: %w", filepath.Dir(dest), err)
+	}
+	if err := os.Chmod(path, 0o755); err != nil {
+		return "", fmt.Errorf("chmod opam: %w", err)
+	}
+	if err := moveFile(path, dest); err != nil {
+		return "", fmt.Errorf("install opam to %s: %w", dest, err)
+	}
+	logger.Log("opam installed to %s", dest)
+	return dest, nil
+}
+
+// moveFile renames src to dst, falling back to a copy when they live on
+// different filesystems (e.g. /tmp on tmpfs).
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o755); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,24 @@
+package opam
+
+import "testing"
+
+func TestNearestVersion(t *testing.T) {
+	versions := []string{"8.18.0", "8.19.2", "8.20.0", "8.20.1", "9.0.0"}
+	tests := []struct {
+		versions []string
+		want     string
+		nearest  string
+	}{
+		{versions, "8.20.1", "8.20.1"},
+		{versions, "8.20.2", "8.20.1"}, // highest not above
+		{versions, "8.19.0", "8.18.0"},
+		{versions, "8.17.1", "8.18.0"}, // else lowest above
+		{versions, "10.0", "9.0.0"},
+		{nil, "8.20.1", ""},
+	}
+	for _, tt := range tests {
+		if got := NearestVersion(tt.versions, tt.want); got != tt.nearest {
+			t.Errorf("NearestVersion(%v, %s) = %q, want %q", tt.versions, tt.want, got, tt.nearest)
+		}
+	}
+}
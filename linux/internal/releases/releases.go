@@ -216,5 +216,13 @@ func FetchManifestForTag(tag string) (*manifest.Manifest, error) {
 		},
 	}
 
+	// The VSCode extension is released together with its language server.
+	for _, name := range []string{"vsrocq-language-server", "vscoq-language-server"} {
+		if ver, ok := pick.pinnedPackages[name]; ok {
+			m.VSCodeExtension.Version = ver
+			break
+		}
+	}
+
 	return m, nil
 }
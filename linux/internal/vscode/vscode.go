@@ -1,12 +1,24 @@
 package vscode
 
 import (
-	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
+	"github.com/justme0606/rocq-bootstrap/linux/internal/wsl"
 	sharedvscode "github.com/justme0606/rocq-bootstrap/shared/vscode"
 )
 
+// Extension IDs of VSRocq and VSCoq.
+const (
+	RocqExtensionID = sharedvscode.RocqExtensionID
+	CoqExtensionID  = sharedvscode.CoqExtensionID
+)
+
+// DefaultProfile is the name of the dedicated VSCode profile for Rocq.
+const DefaultProfile = sharedvscode.DefaultProfile
+
 // IsCoq returns true if the version refers to a Coq release (major version < 9).
 func IsCoq(version string) bool {
 	return sharedvscode.IsCoq(version)
@@ -17,35 +29,163 @@ func ExtensionIDForVersion(rocqVersion string) string {
 	return sharedvscode.ExtensionIDForVersion(rocqVersion)
 }
 
-// InstallExtension installs the given VSCode extension if not already present.
-func InstallExtension(codeBin, extensionID string) error {
-	return sharedvscode.InstallExtension(codeBin, extensionID)
+// InstallExtension installs the given VSCode extension in the profile if not already present.
+func InstallExtension(codeBin, profile, extensionID string) error {
+	return sharedvscode.InstallExtension(codeBin, profile, extensionID)
+}
+
+// InstallExtensionVersion installs a specific version of the given extension in the profile.
+func InstallExtensionVersion(codeBin, profile, extensionID, version string) error {
+	return sharedvscode.InstallExtensionVersion(codeBin, profile, extensionID, version)
+}
+
+// InstalledExtensionVersion returns the version of the given extension installed in the profile.
+func InstalledExtensionVersion(codeBin, profile, extensionID string) (string, error) {
+	return sharedvscode.InstalledExtensionVersion(codeBin, profile, extensionID)
+}
+
+// InstallVSIX installs the extension packaged in the .vsix file at path in the profile.
+func InstallVSIX(codeBin, profile, path string) error {
+	return sharedvscode.InstallVSIX(codeBin, profile, path)
+}
+
+// EnsureExtensionVersion installs the given version of the extension in the profile unless it is the installed one.
+func EnsureExtensionVersion(codeBin, profile, extensionID, version string) (string, error) {
+	return sharedvscode.EnsureExtensionVersion(codeBin, profile, extensionID, version)
+}
+
+// LanguageServerVersion returns the version of the language server at topPath, or "" if unknown.
+func LanguageServerVersion(topPath string) string {
+	return sharedvscode.LanguageServerVersion(topPath)
+}
+
+// CompatibleVersions reports whether the extension version works with the language server version.
+func CompatibleVersions(client, server string) bool {
+	return sharedvscode.CompatibleVersions(client, server)
+}
+
+// ConflictingExtensionID returns the extension that must not be enabled together with extensionID.
+func ConflictingExtensionID(extensionID string) string {
+	return sharedvscode.ConflictingExtensionID(extensionID)
+}
+
+// OpenWorkspace opens VSCode with the given workspace directory in the profile, disabling the given extensions in its window.
+func OpenWorkspace(codeBin, profile, workspaceDir string, disabled ...string) error {
+	return sharedvscode.OpenWorkspace(codeBin, profile, workspaceDir, disabled...)
+}
+
+// UninstallExtension removes the given extension from the profile.
+func UninstallExtension(codeBin, profile, extensionID string) error {
+	return sharedvscode.UninstallExtension(codeBin, profile, extensionID)
+}
+
+// RemovedExtension is an extension the installer uninstalled, recorded to be restored later.
+type RemovedExtension = sharedvscode.RemovedExtension
+
+// Editor is an installed VSCode-family editor.
+type Editor = sharedvscode.Editor
+
+// Sandboxes an editor may be confined in.
+const (
+	SandboxFlatpak = sharedvscode.SandboxFlatpak
+	SandboxSnap    = sharedvscode.SandboxSnap
+)
+
+// RemoteWSL marks a Windows editor used from WSL through Remote-WSL.
+const RemoteWSL = sharedvscode.RemoteWSL
+
+// SelectEditor returns the editor of editors that choice names, or the first one when choice is empty.
+func SelectEditor(editors []Editor, choice string) (*Editor, error) {
+	return sharedvscode.SelectEditor(editors, choice)
+}
+
+// flatpakIDs maps the commands of the VSCode-family editors to the
+// application IDs of their Flathub builds.
+var flatpakIDs = map[string]string{
+	"code":   "com.visualstudio.code",
+	"codium": "com.vscodium.codium",
 }
 
-// OpenWorkspace opens VSCode with the given workspace directory.
-func OpenWorkspace(codeBin, workspaceDir string) error {
-	return sharedvscode.OpenWorkspace(codeBin, workspaceDir)
+// windowsInstallDirs maps the commands of the VSCode-family editors to the
+// directory they install into on Windows and their WSL shim in it.
+var windowsInstallDirs = map[string][2]string{
+	"code":          {"Microsoft VS Code", "bin/code"},
+	"code-insiders": {"Microsoft VS Code Insiders", "bin/code-insiders"},
+	"codium":        {"VSCodium", "bin/codium"},
+	"cursor":        {"cursor", "resources/app/bin/cursor"},
 }
 
-// FindCode searches for the VSCode CLI executable.
-func FindCode() (string, error) {
-	// Try PATH first
-	path, err := exec.LookPath("code")
-	if err == nil {
-		return path, nil
+// FindEditors returns the installed VSCode-family editors: for each flavor
+// the CLI found in PATH, the common install locations or /snap/bin, then
+// its Flatpak build, whose exported launcher accepts the same arguments.
+// In WSL, the Windows editor of each flavor comes first, used through its
+// WSL shim (see RemoteWSL).
+func FindEditors() []Editor {
+	var editors []Editor
+	inWSL := wsl.Detect()
+	for _, f := range sharedvscode.Flavors {
+		if inWSL {
+			if bin := windowsShim(f.Command); bin != "" {
+				editors = append(editors, Editor{Flavor: f, Bin: bin, Remote: RemoteWSL})
+			}
+		}
+		candidates := []string{
+			"/usr/bin/" + f.Command,
+			"/usr/share/" + f.Command + "/bin/" + f.Command,
+			"/snap/bin/" + f.Command,
+		}
+		if f.Command == "cursor" {
+			candidates = append(candidates, "/opt/Cursor/resources/app/bin/cursor")
+		}
+		// In WSL, the command in PATH may be the shim found above.
+		if path, err := exec.LookPath(f.Command); err == nil && !(inWSL && wsl.IsWindowsPath(path)) {
+			candidates = append([]string{path}, candidates...)
+		}
+		for _, c := range candidates {
+			if _, err := exec.LookPath(c); err == nil {
+				e := Editor{Flavor: f, Bin: c}
+				if strings.HasPrefix(c, "/snap/") && snapConfined(f.Command) {
+					e.Sandbox = SandboxSnap
+					home, _ := os.UserHomeDir()
+					e.ConfigRoot = filepath.Join(home, "snap", f.Command, "current", ".config")
+				}
+				editors = append(editors, e)
+				break
+			}
+		}
+
+		if id := flatpakIDs[f.Command]; id != "" {
+			home, _ := os.UserHomeDir()
+			for _, dir := range []string{filepath.Join(home, ".local/share/flatpak"), "/var/lib/flatpak"} {
+				launcher := filepath.Join(dir, "exports/bin", id)
+				if _, err := exec.LookPath(launcher); err == nil {
+					editors = append(editors, Editor{Flavor: f, Bin: launcher, Sandbox: SandboxFlatpak,
+						ConfigRoot: filepath.Join(home, ".var", "app", id, "config")})
+					break
+				}
+			}
+		}
 	}
+	return editors
+}
 
-	// Try common Linux install locations
-	candidates := []string{
-		"/usr/bin/code",
-		"/snap/bin/code",
-		"/usr/share/code/bin/code",
+// windowsShim returns the WSL shim of the Windows editor with the given
+// command, in PATH (which WSL appends the Windows PATH to) or its install
+// directory, or "".
+func windowsShim(command string) string {
+	if path, err := exec.LookPath(command); err == nil && wsl.IsWindowsPath(path) {
+		return path
 	}
-	for _, c := range candidates {
-		if _, err := exec.LookPath(c); err == nil {
-			return c, nil
-		}
+	dir, ok := windowsInstallDirs[command]
+	if !ok {
+		return ""
 	}
+	return wsl.FindWindowsCLI(wsl.WindowsRoot, dir[0], dir[1])
+}
 
-	return "", fmt.Errorf("VSCode (code) not found in PATH or common locations")
+// snapConfined reports whether the snap with the given name is strictly
+// confined: VSCode and VSCodium are published as classic snaps, which are not.
+func snapConfined(name string) bool {
+	out, err := exec.Command("snap", "list", name).Output()
+	return err == nil && !strings.Contains(string(out), "classic")
 }
@@ -0,0 +1,56 @@
+// Package wsl detects the Windows Subsystem for Linux and the Windows
+// VSCode-family editors reachable from it.
+package wsl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProcVersion is the kernel version file Detect reads.
+const ProcVersion = "/proc/version"
+
+// WindowsRoot is where the Windows system drive is mounted in WSL by
+// default.
+const WindowsRoot = "/mnt/c"
+
+// Detect reports whether the process runs in WSL.
+func Detect() bool {
+	return DetectIn(ProcVersion)
+}
+
+// DetectIn reports whether the kernel version file at path is the one of
+// a WSL kernel: "...-microsoft-standard-WSL2..." for WSL 2,
+// "...-Microsoft..." for WSL 1.
+func DetectIn(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.Contains(strings.ToLower(string(data)), "microsoft")
+}
+
+// Distro returns the name of the WSL distribution, or "" if unknown.
+func Distro() string {
+	return os.Getenv("WSL_DISTRO_NAME")
+}
+
+// IsWindowsPath reports whether path is on a mounted Windows drive.
+func IsWindowsPath(path string) bool {
+	return strings.HasPrefix(path, "/mnt/")
+}
+
+// FindWindowsCLI returns the WSL shim of a Windows editor, the file named
+// cli (relative to the install directory dir) in a system or user install
+// under root, or "" if there is none.
+func FindWindowsCLI(root, dir, cli string) string {
+	candidates := []string{
+		filepath.Join(root, "Program Files", dir, cli),
+	}
+	users, _ := filepath.Glob(filepath.Join(root, "Users", "*", "AppData", "Local", "Programs", dir, cli))
+	candidates = append(candidates, users...)
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && !info.IsDir() {
+			return c
+		}
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package wsl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectIn(t *testing.T) {
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{"wsl1", true},
+		{"wsl2", true},
+		{"linux", false},
+		{"missing", false},
+	}
+	for _, tt := range tests {
+		if got := DetectIn(filepath.Join("testdata", "proc-version", tt.file)); got != tt.want {
+			t.Errorf("DetectIn(%s) = %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestIsWindowsPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/mnt/c/Users/me/AppData/Local/Programs/Microsoft VS Code/bin/code", true},
+		{"/usr/bin/code", false},
+		{"/home/me/mnt/code", false},
+	}
+	for _, tt := range tests {
+		if got := IsWindowsPath(tt.path); got != tt.want {
+			t.Errorf("IsWindowsPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+// touch creates the file at the path made of elem, and its directories.
+func touch(t *testing.T, elem ...string) string {
+	t.Helper()
+	path := filepath.Join(elem...)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindWindowsCLI(t *testing.T) {
+	const dir, cli = "Microsoft VS Code", "bin/code"
+
+	// A fake /mnt/c with no editor.
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "Users", "me", "AppData", "Local", "Programs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindWindowsCLI(root, dir, cli); got != "" {
+		t.Errorf("FindWindowsCLI with no editor = %q, want \"\"", got)
+	}
+
+	// A user install.
+	user := touch(t, root, "Users", "me", "AppData", "Local", "Programs", dir, cli)
+	if got := FindWindowsCLI(root, dir, cli); got != user {
+		t.Errorf("FindWindowsCLI with a user install = %q, want %q", got, user)
+	}
+
+	// A system install comes first.
+	system := touch(t, root, "Program Files", dir, cli)
+	if got := FindWindowsCLI(root, dir, cli); got != system {
+		t.Errorf("FindWindowsCLI with a system install = %q, want %q", got, system)
+	}
+
+	// Another flavor is not found in the VSCode directory.
+	if got := FindWindowsCLI(root, "VSCodium", "bin/codium"); got != "" {
+		t.Errorf("FindWindowsCLI(VSCodium) = %q, want \"\"", got)
+	}
+}
+
+// TestFindWindowsCLIIgnoresDirectory checks that a directory named like
+// the CLI is not taken for it.
+func TestFindWindowsCLIIgnoresDirectory(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "Program Files", "cursor", "resources", "app", "bin", "cursor"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindWindowsCLI(root, "cursor", "resources/app/bin/cursor"); got != "" {
+		t.Errorf("FindWindowsCLI = %q, want \"\"", got)
+	}
+}
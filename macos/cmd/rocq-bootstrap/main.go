@@ -1,25 +1,136 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/justme0606/rocq-bootstrap/shared/settings"
 	"github.com/justme0606/rocq-bootstrap/shared/startup"
 
 	rootfs "github.com/justme0606/rocq-bootstrap/macos"
 	"github.com/justme0606/rocq-bootstrap/macos/internal/gui"
 	"github.com/justme0606/rocq-bootstrap/macos/internal/manifest"
+	"github.com/justme0606/rocq-bootstrap/macos/internal/vscode"
+	"github.com/justme0606/rocq-bootstrap/macos/internal/workspace"
 )
 
 var Version = "dev"
 
 func main() {
-	showLog := false
-	for _, arg := range os.Args[1:] {
-		if arg == "--log" {
-			showLog = true
+	opts := &gui.Options{}
+	args := os.Args[1:]
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--log":
+			opts.ShowLog = true
+		case args[i] == "--template" && i+1 < len(args):
+			i++
+			opts.Template = args[i]
+		case strings.HasPrefix(args[i], "--template="):
+			opts.Template = strings.TrimPrefix(args[i], "--template=")
+		case args[i] == "--workspace" && i+1 < len(args):
+			i++
+			opts.WorkspaceDir = args[i]
+		case strings.HasPrefix(args[i], "--workspace="):
+			opts.WorkspaceDir = strings.TrimPrefix(args[i], "--workspace=")
+		case args[i] == "--code-workspace":
+			opts.CodeWorkspace = true
+		case args[i] == "--git":
+			opts.Git = true
+		case args[i] == "--company-coq":
+			opts.CompanyCoq = true
+		case args[i] == "--editor" && i+1 < len(args):
+			i++
+			opts.Editor = args[i]
+		case strings.HasPrefix(args[i], "--editor="):
+			opts.Editor = strings.TrimPrefix(args[i], "--editor=")
+		case args[i] == "--code" && i+1 < len(args):
+			i++
+			opts.CodeEditor = args[i]
+		case strings.HasPrefix(args[i], "--code="):
+			opts.CodeEditor = strings.TrimPrefix(args[i], "--code=")
+		case args[i] == "--vsix" && i+1 < len(args):
+			i++
+			opts.VSIX = args[i]
+		case strings.HasPrefix(args[i], "--vsix="):
+			opts.VSIX = strings.TrimPrefix(args[i], "--vsix=")
+		case args[i] == "--settings-scope" && i+1 < len(args):
+			i++
+			opts.SettingsScope = args[i]
+		case strings.HasPrefix(args[i], "--settings-scope="):
+			opts.SettingsScope = strings.TrimPrefix(args[i], "--settings-scope=")
+		case args[i] == "--profile" && i+1 < len(args):
+			i++
+			opts.Profile = args[i]
+		case strings.HasPrefix(args[i], "--profile="):
+			opts.Profile = strings.TrimPrefix(args[i], "--profile=")
+		case args[i] == "--uninstall-conflicting":
+			opts.UninstallConflicting = true
+		case args[i] == "--build" && i+1 < len(args):
+			i++
+			opts.Build = args[i]
+		case strings.HasPrefix(args[i], "--build="):
+			opts.Build = strings.TrimPrefix(args[i], "--build=")
+		case isWorkspacesCommand(args[i]):
+			if err := workspacesCommand(args[i:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case args[i] == "--restore-extensions":
+			if err := restoreExtensions(); err != nil {
+				fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case args[i] == "--list-templates":
+			if err := listTemplates(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
 		}
 	}
 
+	if opts.Template != "" {
+		if _, err := workspace.LoadTemplate(rootfs.EmbeddedTemplates, opts.Template); err != nil {
+			fmt.Fprintf(os.Stderr, "%v (see --list-templates)\n", err)
+			os.Exit(2)
+		}
+	}
+	if opts.Build != "" && opts.Build != workspace.BuildMake && opts.Build != workspace.BuildDune {
+		fmt.Fprintf(os.Stderr, "unknown build system %q (use %s or %s)\n", opts.Build, workspace.BuildMake, workspace.BuildDune)
+		os.Exit(2)
+	}
+	if opts.Editor != "" && opts.Editor != workspace.EditorVSCode && opts.Editor != workspace.EditorEmacs && opts.Editor != workspace.EditorNeovim {
+		fmt.Fprintf(os.Stderr, "unknown editor %q (use %s, %s or %s)\n", opts.Editor, workspace.EditorVSCode, workspace.EditorEmacs, workspace.EditorNeovim)
+		os.Exit(2)
+	}
+	if opts.SettingsScope != "" && opts.SettingsScope != workspace.ScopeWorkspace && opts.SettingsScope != workspace.ScopeUser {
+		fmt.Fprintf(os.Stderr, "unknown settings scope %q (use %s or %s)\n", opts.SettingsScope, workspace.ScopeWorkspace, workspace.ScopeUser)
+		os.Exit(2)
+	}
+	if opts.CodeEditor != "" {
+		if _, err := vscode.SelectEditor(vscode.FindEditors(), opts.CodeEditor); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if opts.VSIX != "" {
+		// The editor CLI may resolve a relative path elsewhere.
+		path, err := filepath.Abs(opts.VSIX)
+		if err == nil {
+			_, err = os.Stat(path)
+		}
+		if err != nil || !strings.HasSuffix(path, ".vsix") {
+			fmt.Fprintf(os.Stderr, "%s is not a .vsix file\n", opts.VSIX)
+			os.Exit(2)
+		}
+		opts.VSIX = path
+	}
+
 	var m *manifest.Manifest
 
 	startup.Bootstrap(&startup.BootstrapConfig{
@@ -28,8 +139,30 @@ func main() {
 			m, err = manifest.Load(rootfs.EmbeddedManifest, "embedded/manifest/latest.json")
 			return err
 		},
-		RunGUI:          func() { gui.Run(m, rootfs.EmbeddedTemplates, rootfs.EmbeddedIcon, Version, showLog) },
+		RunGUI:          func() { gui.Run(m, rootfs.EmbeddedTemplates, rootfs.EmbeddedIcon, Version, opts) },
 		RocqVersion:     func() string { return m.RocqVersion },
 		PlatformRelease: func() string { return m.PlatformRelease },
 	})
 }
+
+// listTemplates prints the embedded workspace templates.
+func listTemplates() error {
+	list, err := workspace.ListTemplates(rootfs.EmbeddedTemplates)
+	if err != nil {
+		return err
+	}
+	for _, t := range list {
+		fmt.Printf("%-10s %s: %s\n", t.ID, t.Name, t.Description)
+	}
+	return nil
+}
+
+// restoreExtensions installs again the extensions uninstalled because they
+// conflicted with the one the installer set up.
+func restoreExtensions() error {
+	restored, err := settings.RestoreExtensions()
+	for _, r := range restored {
+		fmt.Printf("Restored: %s %s (%s)\n", r.ID, r.Version, r.Editor)
+	}
+	return err
+}
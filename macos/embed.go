@@ -7,9 +7,10 @@ import "embed"
 //go:embed embedded/manifest/latest.json
 var EmbeddedManifest embed.FS
 
-// EmbeddedTemplates contains workspace template files.
+// EmbeddedTemplates contains the workspace template bundles, one directory
+// per template ("all:" keeps files such as _RocqProject).
 //
-//go:embed embedded/templates/*
+//go:embed all:embedded/templates
 var EmbeddedTemplates embed.FS
 
 // EmbeddedIcon contains the application icon.
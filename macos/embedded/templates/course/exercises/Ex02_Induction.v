@@ -0,0 +1,10 @@
+(** * Exercise 2: induction on natural numbers *)
+
+Theorem add_0_r : forall n : nat, n + 0 = n.
+Proof.
+  (* Hint: use induction n. *)
+Admitted.
+
+Theorem add_comm : forall n m : nat, n + m = m + n.
+Proof.
+Admitted.
@@ -0,0 +1,6 @@
+(** Build with: dune build *)
+
+Theorem plus_O_n : forall n : nat, 0 + n = n.
+Proof.
+  intros n. reflexivity.
+Qed.
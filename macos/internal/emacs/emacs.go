@@ -0,0 +1,51 @@
+package emacs
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	sharedemacs "github.com/justme0606/rocq-bootstrap/shared/emacs"
+)
+
+// Emacs packages set up by the installer.
+const (
+	ProofGeneral = sharedemacs.ProofGeneral
+	CompanyCoq   = sharedemacs.CompanyCoq
+)
+
+// HasPackage reports whether Emacs finds the given package.
+func HasPackage(emacsBin, pkg string) bool {
+	return sharedemacs.HasPackage(emacsBin, pkg)
+}
+
+// InstallPackage installs the given package from MELPA if not already present.
+func InstallPackage(emacsBin, pkg string) error {
+	return sharedemacs.InstallPackage(emacsBin, pkg)
+}
+
+// FindEmacs searches for the Emacs executable on macOS.
+func FindEmacs() (string, error) {
+	// 1. Try PATH first
+	path, err := exec.LookPath("emacs")
+	if err == nil {
+		return path, nil
+	}
+
+	// 2. Emacs.app (emacsformacosx.com, Homebrew cask), system-wide or per user
+	candidates := []string{"/Applications/Emacs.app/Contents/MacOS/Emacs"}
+	if home, _ := os.UserHomeDir(); home != "" {
+		candidates = append(candidates, filepath.Join(home, "Applications/Emacs.app/Contents/MacOS/Emacs"))
+	}
+
+	// 3. Homebrew formula location
+	candidates = append(candidates, "/opt/homebrew/bin/emacs", "/usr/local/bin/emacs")
+	for _, p := range candidates {
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p, nil
+		}
+	}
+
+	return "", fmt.Errorf("Emacs not found in PATH or common locations")
+}
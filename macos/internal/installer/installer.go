@@ -10,7 +10,9 @@ import (
 	"strings"
 
 	sharedinstaller "github.com/justme0606/rocq-bootstrap/shared/installer"
+	"github.com/justme0606/rocq-bootstrap/shared/settings"
 
+	"github.com/justme0606/rocq-bootstrap/macos/internal/emacs"
 	"github.com/justme0606/rocq-bootstrap/macos/internal/manifest"
 	"github.com/justme0606/rocq-bootstrap/macos/internal/vscode"
 	"github.com/justme0606/rocq-bootstrap/macos/internal/workspace"
@@ -29,10 +31,6 @@ func debugLog(format string, args ...interface{}) {
 	log.Printf(format, args...)
 }
 
-const (
-	WorkspaceName = "rocq-workspace"
-)
-
 // DefaultInstallDir returns the default installation directory for macOS.
 func DefaultInstallDir() string {
 	return "/Applications"
@@ -43,12 +41,24 @@ type StepFunc func(step int, label string, fraction float64)
 
 // Config holds all parameters for the installation pipeline.
 type Config struct {
-	Manifest    *manifest.Manifest
-	Templates   fs.FS
-	SkipInstall bool   // If true, skip download/checksum/install steps (reuse existing installation)
-	ExistingApp string // Path to existing .app if reusing
-	OnStep      StepFunc
-	Logger      *Logger
+	Manifest             *manifest.Manifest
+	Templates            fs.FS
+	Template             string // workspace template ID; empty means workspace.DefaultTemplate
+	WorkspaceDir         string // workspace directory; empty means ~/rocq-workspace
+	CodeWorkspace        bool   // also write a .code-workspace file and open VSCode with it
+	Editor               string // editor to configure (workspace.EditorVSCode, EditorEmacs, EditorNeovim); empty means VSCode, or Emacs if only Emacs is found
+	CodeEditor           string // VSCode-family editor to use, by CLI or label (see vscode.FindEditors); empty means the first one found
+	SettingsScope        string // scope of the language server setting (workspace.ScopeWorkspace, ScopeUser); empty means the workspace
+	Profile              string // VSCode profile to install the extension in and open the workspace with (see vscode.DefaultProfile); empty means the default profile
+	VSIX                 string // local .vsix of the extension, installed instead of the manifest's or the Marketplace's
+	UninstallConflicting bool   // uninstall the conflicting VSCoq/VSRocq extension rather than disable it in the window opened by the installer
+	CompanyCoq           bool   // with Emacs, also set up company-coq
+	Build                string // build system to set up (workspace.BuildMake, BuildDune); empty means none
+	Git                  bool   // initialize the workspace as a git repository
+	SkipInstall          bool   // If true, skip download/checksum/install steps (reuse existing installation)
+	ExistingApp          string // Path to existing .app if reusing
+	OnStep               StepFunc
+	Logger               *Logger
 }
 
 // FindExistingInstallations searches for all existing Rocq Platform installations.
@@ -124,22 +134,23 @@ func FindExistingInstallations() []string {
 
 // Result holds information about the installation outcome.
 type Result struct {
-	VSCodeFound    bool   // Whether VSCode was detected on the system
-	InstalledApp   string // Path to the installed .app
-	VsrocqtopPath  string // Path to vsrocqtop binary
+	VSCodeFound   bool   // Whether VSCode was detected on the system
+	Editor        string // editor configured instead of VSCode (workspace.EditorEmacs, EditorNeovim); empty otherwise
+	InstalledApp  string // Path to the installed .app
+	VsrocqtopPath string // Path to vsrocqtop binary
+	WorkspaceDir  string // Path to the workspace
 }
 
 // Run executes the installation pipeline.
 func Run(cfg *Config) (*Result, error) {
 	asset := cfg.Manifest.Assets.MacOS.ARM64
 
-	result := &Result{}
-
-	home, err := os.UserHomeDir()
+	workspaceDir, err := workspace.ResolveDir(cfg.WorkspaceDir)
 	if err != nil {
-		return nil, fmt.Errorf("get home dir: %w", err)
+		return nil, fmt.Errorf("workspace: %w", err)
 	}
-	workspaceDir := filepath.Join(home, WorkspaceName)
+
+	result := &Result{WorkspaceDir: workspaceDir}
 
 	var installedAppPath string
 
@@ -228,57 +239,244 @@ func Run(cfg *Config) (*Result, error) {
 		cfg.OnStep(4, fmt.Sprintf("Found %s.", topBinLabel), 1.0)
 	}
 
-	// Step 5: Check for VSCode
-	cfg.OnStep(5, "Checking for VSCode...", 0.0)
-	codeBin, err := vscode.FindCode()
-	if err != nil {
-		cfg.Logger.Log("VSCode not found: %v", err)
-		cfg.OnStep(5, "VSCode not found.", 1.0)
-		cfg.OnStep(6, "Skipped (VSCode not found).", 1.0)
-		cfg.OnStep(7, "Skipped (VSCode not found).", 1.0)
-		result.VSCodeFound = false
-		return result, nil
+	// Step 5: Check for VSCode, unless another editor was chosen (Emacs
+	// also when it is the only editor found)
+	editorID := cfg.Editor
+	var codeEditor *vscode.Editor
+	if editorID == "" || editorID == workspace.EditorVSCode {
+		cfg.OnStep(5, "Checking for VSCode...", 0.0)
+		codeEditor, err = vscode.SelectEditor(vscode.FindEditors(), cfg.CodeEditor)
+		if err != nil {
+			cfg.Logger.Log("VSCode not found: %v", err)
+			if _, emacsErr := emacs.FindEmacs(); editorID == "" && emacsErr == nil {
+				cfg.Logger.Log("Emacs found, configuring Proof General instead")
+				editorID = workspace.EditorEmacs
+			} else {
+				cfg.OnStep(5, "VSCode not found.", 1.0)
+				cfg.OnStep(6, "Skipped (VSCode not found).", 1.0)
+				cfg.OnStep(7, "Skipped (VSCode not found).", 1.0)
+				result.VSCodeFound = false
+				return result, nil
+			}
+		}
 	}
-	result.VSCodeFound = true
 
-	// VSCode found — install extension
-	cfg.Logger.Log("VSCode CLI: %s", codeBin)
-	extensionID := vscode.ExtensionIDForVersion(cfg.Manifest.RocqVersion)
-	if err := vscode.InstallExtension(codeBin, extensionID); err != nil {
-		cfg.Logger.Log("WARNING: extension install failed: %v", err)
+	var extensionID string
+	var disabled []string // conflicting extensions to disable in the workspace window
+	switch editorID {
+	case workspace.EditorEmacs:
+		cfg.OnStep(5, "Using Emacs.", 1.0)
+	case workspace.EditorNeovim:
+		cfg.OnStep(5, "Using Neovim.", 1.0)
+	default:
+		result.VSCodeFound = true
+
+		// VSCode found — install extension
+		cfg.Logger.Log("%s CLI: %s (extensions from %s)", codeEditor.Label(), codeEditor.Bin, codeEditor.Marketplace())
+		if cfg.Profile != "" {
+			cfg.Logger.Log("Using VSCode profile %q", cfg.Profile)
+		}
+		extensionID = vscode.ExtensionIDForVersion(cfg.Manifest.RocqVersion)
+		fromVSIX, err := installVSIX(cfg, codeEditor.Bin)
+		if err != nil {
+			cfg.Logger.Log("WARNING: %v, installing from %s instead", err, codeEditor.Marketplace())
+		}
+		switch {
+		case fromVSIX:
+			// The package sets the extension version.
+		case cfg.Manifest.VSCodeExtension.Version != "":
+			installPinnedExtension(codeEditor.Bin, cfg.Profile, extensionID, cfg.Manifest.VSCodeExtension.Version, cfg.Logger)
+		default:
+			if err := vscode.InstallExtension(codeEditor.Bin, cfg.Profile, extensionID); err != nil {
+				cfg.Logger.Log("WARNING: extension install failed: %v", err)
+			}
+		}
+		disabled = resolveConflict(cfg, codeEditor.Bin, extensionID)
+		cfg.OnStep(5, "VSCode extension installed.", 1.0)
 	}
-	cfg.OnStep(5, "VSCode extension installed.", 1.0)
 
 	// Step 6: Create workspace
 	cfg.OnStep(6, "Creating workspace...", 0.0)
 	cfg.Logger.Log("Creating workspace at %s", workspaceDir)
-	if err := workspace.Create(workspaceDir, cfg.Templates); err != nil {
+	vars := workspace.TemplateVars{
+		RocqVersion:     cfg.Manifest.RocqVersion,
+		PlatformRelease: cfg.Manifest.PlatformRelease,
+		InstallDir:      result.InstalledApp,
+	}
+	if err := workspace.Create(workspaceDir, cfg.Templates, cfg.Template, vars); err != nil {
 		return nil, fmt.Errorf("workspace: %w", err)
 	}
 	cfg.Logger.Log("Workspace created")
+	if err := settings.RememberWorkspace(workspaceDir); err != nil {
+		cfg.Logger.Log("WARNING: could not remember workspace location: %v", err)
+	}
+	if cfg.Build != "" {
+		cfg.OnStep(6, fmt.Sprintf("Building workspace with %s...", cfg.Build), 0.5)
+		if err := setUpBuild(workspaceDir, cfg.Build, vscode.IsCoq(cfg.Manifest.RocqVersion), taskEnv(vsrocqtopPath), cfg.Logger); err != nil {
+			return nil, fmt.Errorf("build check: %w", err)
+		}
+	}
+	if cfg.Git {
+		initGit(workspaceDir, cfg.Logger)
+	}
+	if vsrocqtopPath != "" {
+		if err := writeActivation(workspaceDir, vsrocqtopPath); err != nil {
+			return nil, fmt.Errorf("activation scripts: %w", err)
+		}
+	} else {
+		cfg.Logger.Log("Skipping activation scripts (%s not found)", topBinLabel)
+	}
 	cfg.OnStep(6, "Workspace created.", 1.0)
 
-	// Step 7: Configure VSCode settings and open workspace
+	// Step 7: Configure the editor chosen instead of VSCode, or VSCode
+	// settings and open workspace
+	if codeEditor == nil {
+		env := &workspace.ActivationEnv{Path: []string{filepath.Dir(vsrocqtopPath)}}
+		switch {
+		case vsrocqtopPath == "":
+			cfg.Logger.Log("Skipping editor configuration (%s not found)", topBinLabel)
+		case editorID == workspace.EditorEmacs:
+			cfg.OnStep(7, "Configuring Emacs...", 0.0)
+			if err := configureEmacs(workspaceDir, env, cfg.CompanyCoq, cfg.Logger); err != nil {
+				cfg.Logger.Log("WARNING: Emacs not configured: %v", err)
+			} else {
+				result.Editor = editorID
+			}
+		case editorID == workspace.EditorNeovim:
+			cfg.OnStep(7, "Configuring Neovim...", 0.0)
+			if err := configureVim(workspaceDir, env, vsrocqtopPath, cfg.Logger); err != nil {
+				cfg.Logger.Log("WARNING: Neovim not configured: %v", err)
+			} else {
+				result.Editor = editorID
+			}
+		}
+		register(cfg.Manifest, workspaceDir, result.InstalledApp, vsrocqtopPath, "", "", cfg.Logger)
+		cfg.OnStep(7, "Done!", 1.0)
+		return result, nil
+	}
+
 	cfg.OnStep(7, "Configuring VSCode...", 0.0)
+	var userSettings string
 	if vsrocqtopPath != "" {
 		settingsKey := "vsrocq.path"
 		if vscode.IsCoq(cfg.Manifest.RocqVersion) {
 			settingsKey = "vscoq.path"
 		}
-		if err := workspace.WriteVSCodeSettings(workspaceDir, settingsKey, vsrocqtopPath); err != nil {
-			return nil, fmt.Errorf("vscode config: %w", err)
+		userSettings = userSettingsFile(cfg, codeEditor)
+		if err := workspace.WriteScopedSettings(workspaceDir, userSettings, map[string]interface{}{settingsKey: vsrocqtopPath}); err != nil {
+			cfg.Logger.Log("WARNING: VSCode settings not updated: %v", err)
+			userSettings = ""
+		} else if userSettings != "" {
+			cfg.Logger.Log("VSCode user settings %s written with %s=%s", userSettings, settingsKey, vsrocqtopPath)
+		} else {
+			cfg.Logger.Log("VSCode settings written with %s=%s", settingsKey, vsrocqtopPath)
 		}
-		cfg.Logger.Log("VSCode settings written with %s=%s", settingsKey, vsrocqtopPath)
 	} else {
 		cfg.Logger.Log("Skipping VSCode settings (%s not found)", topBinLabel)
 	}
 
+	tasks := workspace.BuildTasks(workspaceDir, vscode.IsCoq(cfg.Manifest.RocqVersion))
+	writeProjectFiles(workspaceDir, extensionID, tasks, taskEnv(vsrocqtopPath), cfg.CodeWorkspace, cfg.Logger)
+	register(cfg.Manifest, workspaceDir, result.InstalledApp, vsrocqtopPath, cfg.Profile, userSettings, cfg.Logger)
+
 	// Open VSCode with the workspace
-	cfg.Logger.Log("Opening VSCode with workspace %s", workspaceDir)
-	if err := vscode.OpenWorkspace(codeBin, workspaceDir); err != nil {
+	openPath := workspaceDir
+	if cfg.CodeWorkspace {
+		openPath = workspace.CodeWorkspaceFile(workspaceDir)
+	}
+	cfg.Logger.Log("Opening VSCode with workspace %s", openPath)
+	if err := vscode.OpenWorkspace(codeEditor.Bin, cfg.Profile, openPath, disabled...); err != nil {
 		cfg.Logger.Log("WARNING: failed to open VSCode: %v", err)
 	}
 	cfg.OnStep(7, "Done!", 1.0)
 
 	return result, nil
 }
+
+// userSettingsFile returns the user settings file of codeEditor, in
+// cfg.Profile, the language server path goes into with cfg.SettingsScope,
+// or "" for the workspace settings. It is called after the extension is
+// installed, which creates the profile.
+func userSettingsFile(cfg *Config, codeEditor *vscode.Editor) string {
+	if cfg.SettingsScope != workspace.ScopeUser {
+		return ""
+	}
+	path, err := codeEditor.UserSettingsPath(cfg.Profile)
+	if err != nil {
+		cfg.Logger.Log("WARNING: %v; writing the workspace settings instead", err)
+		return ""
+	}
+	return path
+}
+
+// resolveConflict handles the extension conflicting with extensionID when
+// it is installed: with cfg.UninstallConflicting it is uninstalled and
+// recorded for settings.RestoreExtensions; otherwise it is returned, to be
+// disabled in the window the installer opens. VSCode does not keep that
+// for the next windows of the workspace.
+func resolveConflict(cfg *Config, codeBin, extensionID string) []string {
+	conflict := vscode.ConflictingExtensionID(extensionID)
+	version, err := vscode.InstalledExtensionVersion(codeBin, cfg.Profile, conflict)
+	if err != nil {
+		cfg.Logger.Log("WARNING: could not check for %s: %v", conflict, err)
+		return nil
+	}
+	if version == "" {
+		return nil
+	}
+	if !cfg.UninstallConflicting {
+		cfg.Logger.Log("WARNING: %s %s conflicts with %s; disabled in the window opened now only, as VSCode does not keep it: use \"Disable (Workspace)\" in the Extensions view to keep it off in this workspace, or rerun with --uninstall-conflicting", conflict, version, extensionID)
+		return []string{conflict}
+	}
+	if err := vscode.UninstallExtension(codeBin, cfg.Profile, conflict); err != nil {
+		cfg.Logger.Log("WARNING: %v", err)
+		return []string{conflict}
+	}
+	cfg.Logger.Log("Uninstalled %s %s, which conflicts with %s (restore it with --restore-extensions)", conflict, version, extensionID)
+	if err := settings.RecordRemovedExtension(vscode.RemovedExtension{Editor: codeBin, Profile: cfg.Profile, ID: conflict, Version: version}); err != nil {
+		cfg.Logger.Log("WARNING: %s not recorded for restore: %v", conflict, err)
+	}
+	return nil
+}
+
+// installPinnedExtension installs the extension version pinned in the
+// manifest, replacing a mismatched installed one, or else the latest one.
+func installPinnedExtension(codeBin, profile, extensionID, version string, logger *Logger) {
+	previous, err := vscode.EnsureExtensionVersion(codeBin, profile, extensionID, version)
+	if err == nil {
+		if previous != version {
+			logger.Log("%s %s installed (was %q)", extensionID, version, previous)
+		}
+		return
+	}
+	logger.Log("WARNING: %s %s install failed, installing the latest version: %v", extensionID, version, err)
+	if err := vscode.InstallExtension(codeBin, profile, extensionID); err != nil {
+		logger.Log("WARNING: extension install failed: %v", err)
+	}
+}
+
+// installVSIX installs the extension from the local .vsix given in
+// cfg.VSIX, or else from the one pinned in the manifest, downloaded and
+// verified. It reports false when there is no .vsix to install from.
+func installVSIX(cfg *Config, codeBin string) (bool, error) {
+	path := cfg.VSIX
+	if path == "" {
+		vsix := cfg.Manifest.VSCodeExtension.VSIX
+		if vsix == nil || vsix.URL == "" {
+			return false, nil
+		}
+		tempDir, err := os.MkdirTemp("", "rocq-bootstrap-vsix-")
+		if err != nil {
+			return false, fmt.Errorf("create temp dir: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+		if path, err = sharedinstaller.FetchVSIX(vsix.URL, vsix.SHA256, tempDir, cfg.Logger); err != nil {
+			return false, err
+		}
+	}
+	cfg.Logger.Log("Installing the extension from %s", path)
+	if err := vscode.InstallVSIX(codeBin, cfg.Profile, path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -25,6 +25,8 @@ type Manifest struct {
 	RocqVersion     string `json:"rocq_version"`
 	PlatformRelease string `json:"platform_release"`
 	Assets          Assets `json:"assets"`
+
+	VSCodeExtension sharedmanifest.VSCodeExtension `json:"vscode_extension"`
 }
 
 // Parse parses a manifest from raw JSON bytes.
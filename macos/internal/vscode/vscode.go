@@ -1,7 +1,6 @@
 package vscode
 
 import (
-	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -9,6 +8,15 @@ import (
 	sharedvscode "github.com/justme0606/rocq-bootstrap/shared/vscode"
 )
 
+// Extension IDs of VSRocq and VSCoq.
+const (
+	RocqExtensionID = sharedvscode.RocqExtensionID
+	CoqExtensionID  = sharedvscode.CoqExtensionID
+)
+
+// DefaultProfile is the name of the dedicated VSCode profile for Rocq.
+const DefaultProfile = sharedvscode.DefaultProfile
+
 // IsCoq returns true if the version refers to a Coq release (major version < 9).
 func IsCoq(version string) bool {
 	return sharedvscode.IsCoq(version)
@@ -19,49 +27,93 @@ func ExtensionIDForVersion(rocqVersion string) string {
 	return sharedvscode.ExtensionIDForVersion(rocqVersion)
 }
 
-// InstallExtension installs the given VSCode extension if not already present.
-func InstallExtension(codeBin, extensionID string) error {
-	return sharedvscode.InstallExtension(codeBin, extensionID)
+// InstallExtension installs the given VSCode extension in the profile if not already present.
+func InstallExtension(codeBin, profile, extensionID string) error {
+	return sharedvscode.InstallExtension(codeBin, profile, extensionID)
 }
 
-// OpenWorkspace opens VSCode with the given workspace directory.
-func OpenWorkspace(codeBin, workspaceDir string) error {
-	return sharedvscode.OpenWorkspace(codeBin, workspaceDir)
+// InstallVSIX installs the extension packaged in the .vsix file at path in the profile.
+func InstallVSIX(codeBin, profile, path string) error {
+	return sharedvscode.InstallVSIX(codeBin, profile, path)
 }
 
-// FindCode searches for the VSCode CLI executable on macOS.
-func FindCode() (string, error) {
-	// 1. Try PATH first
-	path, err := exec.LookPath("code")
-	if err == nil {
-		return path, nil
-	}
+// EnsureExtensionVersion installs the given version of the extension in the profile unless it is the installed one.
+func EnsureExtensionVersion(codeBin, profile, extensionID, version string) (string, error) {
+	return sharedvscode.EnsureExtensionVersion(codeBin, profile, extensionID, version)
+}
 
-	// 2. Standard macOS app bundle location
-	appBundlePath := "/Applications/Visual Studio Code.app/Contents/Resources/app/bin/code"
-	if info, err := os.Stat(appBundlePath); err == nil && !info.IsDir() {
-		return appBundlePath, nil
-	}
+// LanguageServerVersion returns the version of the language server at topPath, or "" if unknown.
+func LanguageServerVersion(topPath string) string {
+	return sharedvscode.LanguageServerVersion(topPath)
+}
+
+// CompatibleVersions reports whether the extension version works with the language server version.
+func CompatibleVersions(client, server string) bool {
+	return sharedvscode.CompatibleVersions(client, server)
+}
+
+// ConflictingExtensionID returns the extension that must not be enabled together with extensionID.
+func ConflictingExtensionID(extensionID string) string {
+	return sharedvscode.ConflictingExtensionID(extensionID)
+}
+
+// InstalledExtensionVersion returns the version of the given extension installed in the profile.
+func InstalledExtensionVersion(codeBin, profile, extensionID string) (string, error) {
+	return sharedvscode.InstalledExtensionVersion(codeBin, profile, extensionID)
+}
+
+// OpenWorkspace opens VSCode with the given workspace directory in the profile, disabling the given extensions in its window.
+func OpenWorkspace(codeBin, profile, workspaceDir string, disabled ...string) error {
+	return sharedvscode.OpenWorkspace(codeBin, profile, workspaceDir, disabled...)
+}
+
+// UninstallExtension removes the given extension from the profile.
+func UninstallExtension(codeBin, profile, extensionID string) error {
+	return sharedvscode.UninstallExtension(codeBin, profile, extensionID)
+}
+
+// RemovedExtension is an extension the installer uninstalled, recorded to be restored later.
+type RemovedExtension = sharedvscode.RemovedExtension
+
+// Editor is an installed VSCode-family editor.
+type Editor = sharedvscode.Editor
+
+// SelectEditor returns the editor of editors that choice names, or the first one when choice is empty.
+func SelectEditor(editors []Editor, choice string) (*Editor, error) {
+	return sharedvscode.SelectEditor(editors, choice)
+}
 
-	// 3. User Applications folder
+// appBundles maps the commands of the VSCode-family editors to their app
+// bundle and the CLI inside it.
+var appBundles = map[string]string{
+	"code":          "Visual Studio Code.app/Contents/Resources/app/bin/code",
+	"code-insiders": "Visual Studio Code - Insiders.app/Contents/Resources/app/bin/code",
+	"codium":        "VSCodium.app/Contents/Resources/app/bin/codium",
+	"cursor":        "Cursor.app/Contents/Resources/app/bin/cursor",
+}
+
+// FindEditors returns the installed VSCode-family editors, looking for the
+// CLI of each flavor in PATH, in its app bundle (in /Applications or
+// ~/Applications) and in the Homebrew prefixes.
+func FindEditors() []Editor {
 	home, _ := os.UserHomeDir()
-	if home != "" {
-		userAppPath := filepath.Join(home, "Applications/Visual Studio Code.app/Contents/Resources/app/bin/code")
-		if info, err := os.Stat(userAppPath); err == nil && !info.IsDir() {
-			return userAppPath, nil
+	var editors []Editor
+	for _, f := range sharedvscode.Flavors {
+		var candidates []string
+		if path, err := exec.LookPath(f.Command); err == nil {
+			candidates = append(candidates, path)
 		}
-	}
-
-	// 4. Homebrew cask location
-	brewPaths := []string{
-		"/opt/homebrew/bin/code",
-		"/usr/local/bin/code",
-	}
-	for _, p := range brewPaths {
-		if info, err := os.Stat(p); err == nil && !info.IsDir() {
-			return p, nil
+		candidates = append(candidates, filepath.Join("/Applications", appBundles[f.Command]))
+		if home != "" {
+			candidates = append(candidates, filepath.Join(home, "Applications", appBundles[f.Command]))
+		}
+		candidates = append(candidates, "/opt/homebrew/bin/"+f.Command, "/usr/local/bin/"+f.Command)
+		for _, c := range candidates {
+			if info, err := os.Stat(c); err == nil && !info.IsDir() {
+				editors = append(editors, Editor{Flavor: f, Bin: c})
+				break
+			}
 		}
 	}
-
-	return "", fmt.Errorf("VSCode (code) not found in PATH or common locations")
+	return editors
 }
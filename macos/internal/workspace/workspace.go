@@ -6,13 +6,173 @@ import (
 	sharedworkspace "github.com/justme0606/rocq-bootstrap/shared/workspace"
 )
 
-// Create creates the workspace directory with template files.
-// Existing files are not overwritten.
-func Create(workspaceDir string, templates fs.FS) error {
-	return sharedworkspace.Create(workspaceDir, templates)
+// ResolveDir returns the absolute workspace directory for dir; empty means ~/rocq-workspace.
+func ResolveDir(dir string) (string, error) {
+	return sharedworkspace.ResolveDir(dir)
 }
 
-// WriteVSCodeSettings writes .vscode/settings.json with the language server path.
-func WriteVSCodeSettings(workspaceDir, settingsKey, topPath string) error {
-	return sharedworkspace.WriteVSCodeSettings(workspaceDir, settingsKey, topPath)
+// DefaultTemplate is the template used when none is chosen.
+const DefaultTemplate = sharedworkspace.DefaultTemplate
+
+// Template is a named bundle of workspace files.
+type Template = sharedworkspace.Template
+
+// ListTemplates returns the embedded workspace templates.
+func ListTemplates(templates fs.FS) ([]*Template, error) {
+	return sharedworkspace.ListTemplates(templates)
+}
+
+// LoadTemplate reads the description of the template with the given ID.
+func LoadTemplate(templates fs.FS, id string) (*Template, error) {
+	return sharedworkspace.LoadTemplate(templates, id)
+}
+
+// TemplateVars are the values available to rendered template files.
+type TemplateVars = sharedworkspace.TemplateVars
+
+// Create creates the workspace directory with the files of the given template,
+// rendering .tmpl files with vars. Existing files are not overwritten.
+func Create(workspaceDir string, templates fs.FS, templateID string, vars TemplateVars) error {
+	return sharedworkspace.Create(workspaceDir, templates, templateID, vars)
+}
+
+// WriteVSCodeSettings merges the given settings into .vscode/settings.json.
+func WriteVSCodeSettings(workspaceDir string, settings map[string]interface{}) error {
+	return sharedworkspace.WriteVSCodeSettings(workspaceDir, settings)
+}
+
+// ReadVSCodeSettings returns the settings in .vscode/settings.json.
+func ReadVSCodeSettings(workspaceDir string) (map[string]interface{}, error) {
+	return sharedworkspace.ReadVSCodeSettings(workspaceDir)
+}
+
+// Scopes the language server setting can be written in.
+const (
+	ScopeWorkspace = sharedworkspace.ScopeWorkspace
+	ScopeUser      = sharedworkspace.ScopeUser
+)
+
+// LanguageServerKeys are the settings naming the language server of VSRocq and VSCoq.
+var LanguageServerKeys = sharedworkspace.LanguageServerKeys
+
+// WriteScopedSettings writes settings into .vscode/settings.json, or the language server paths into the userSettings file when not empty.
+func WriteScopedSettings(workspaceDir, userSettings string, settings map[string]interface{}) error {
+	return sharedworkspace.WriteScopedSettings(workspaceDir, userSettings, settings)
+}
+
+// ReadUserSettings returns the settings in the VSCode user settings file at path.
+func ReadUserSettings(path string) (map[string]interface{}, error) {
+	return sharedworkspace.ReadUserSettings(path)
+}
+
+// Task is a VSCode task running a process in the workspace.
+type Task = sharedworkspace.Task
+
+// BuildTasks returns the build and clean tasks for the build system found in workspaceDir.
+func BuildTasks(workspaceDir string, coq bool) []Task {
+	return sharedworkspace.BuildTasks(workspaceDir, coq)
+}
+
+// RunThrough returns tasks with each command run through command and its leading args.
+func RunThrough(tasks []Task, command string, args ...string) []Task {
+	return sharedworkspace.RunThrough(tasks, command, args...)
+}
+
+// WriteVSCodeTasks merges tasks into .vscode/tasks.json.
+func WriteVSCodeTasks(workspaceDir string, tasks []Task, env map[string]string) error {
+	return sharedworkspace.WriteVSCodeTasks(workspaceDir, tasks, env)
+}
+
+// WriteVSCodeExtensions merges extension recommendations into .vscode/extensions.json.
+func WriteVSCodeExtensions(workspaceDir, recommended string, unwanted ...string) error {
+	return sharedworkspace.WriteVSCodeExtensions(workspaceDir, recommended, unwanted...)
+}
+
+// CodeWorkspaceFile returns the path of the .code-workspace file of the workspace.
+func CodeWorkspaceFile(workspaceDir string) string {
+	return sharedworkspace.CodeWorkspaceFile(workspaceDir)
+}
+
+// WriteCodeWorkspace merges the workspace folder into its .code-workspace file.
+func WriteCodeWorkspace(workspaceDir string) error {
+	return sharedworkspace.WriteCodeWorkspace(workspaceDir)
+}
+
+// Build systems that WriteBuildFiles can set up.
+const (
+	BuildMake = sharedworkspace.BuildMake
+	BuildDune = sharedworkspace.BuildDune
+)
+
+// WriteBuildFiles sets up the given build system for the project described by _RocqProject.
+func WriteBuildFiles(workspaceDir, system string, coq bool) error {
+	return sharedworkspace.WriteBuildFiles(workspaceDir, system, coq)
+}
+
+// RunBuild runs the default build task of tasks, after the tasks it depends on.
+func RunBuild(workspaceDir string, tasks []Task, env map[string]string) error {
+	return sharedworkspace.RunBuild(workspaceDir, tasks, env)
+}
+
+// InitGit makes the workspace a git repository with a .gitignore for the build
+// artifacts and an initial commit; in an existing repository it only completes .gitignore.
+func InitGit(workspaceDir string) error {
+	return sharedworkspace.InitGit(workspaceDir)
+}
+
+// ActivationEnv describes the environment enabled by the activation scripts.
+type ActivationEnv = sharedworkspace.ActivationEnv
+
+// WriteActivationScripts generates the activation scripts putting the Rocq Platform binaries on PATH.
+func WriteActivationScripts(workspaceDir string, env *ActivationEnv) error {
+	return sharedworkspace.WriteActivationScripts(workspaceDir, env)
+}
+
+// Editors the installer can configure for the workspace.
+const (
+	EditorVSCode = sharedworkspace.EditorVSCode
+	EditorEmacs  = sharedworkspace.EditorEmacs
+	EditorNeovim = sharedworkspace.EditorNeovim
+)
+
+// SetupHint tells the user how to load the configuration written for the editor.
+func SetupHint(editor, workspaceDir string) string {
+	return sharedworkspace.SetupHint(editor, workspaceDir)
+}
+
+// EmacsFile is the Emacs Lisp file configuring Proof General for the workspace.
+const EmacsFile = sharedworkspace.EmacsFile
+
+// EmacsLoadForm returns the form loading the EmacsFile of the workspace from an init file.
+func EmacsLoadForm(workspaceDir string) string {
+	return sharedworkspace.EmacsLoadForm(workspaceDir)
+}
+
+// WriteEmacsConfig writes the EmacsFile pointing Proof General at the binaries in env.Path.
+func WriteEmacsConfig(workspaceDir string, env *ActivationEnv, companyCoq bool) error {
+	return sharedworkspace.WriteEmacsConfig(workspaceDir, env, companyCoq)
+}
+
+// EmacsConfig is what the EmacsFile of a workspace sets up.
+type EmacsConfig = sharedworkspace.EmacsConfig
+
+// ReadEmacsConfig parses the EmacsFile of the workspace.
+func ReadEmacsConfig(workspaceDir string) (*EmacsConfig, error) {
+	return sharedworkspace.ReadEmacsConfig(workspaceDir)
+}
+
+// Local configuration files of the workspace for Neovim and Vim.
+const (
+	NeovimFile = sharedworkspace.NeovimFile
+	VimFile    = sharedworkspace.VimFile
+)
+
+// WriteVimConfig writes the Neovim (language server, Coqtail) and Vim (Coqtail) files of the workspace.
+func WriteVimConfig(workspaceDir string, env *ActivationEnv, topPath string) error {
+	return sharedworkspace.WriteVimConfig(workspaceDir, env, topPath)
+}
+
+// HasVimConfig reports whether the workspace has Neovim or Vim files written by WriteVimConfig.
+func HasVimConfig(workspaceDir string) bool {
+	return sharedworkspace.HasVimConfig(workspaceDir)
 }
@@ -21,6 +21,15 @@ TAG=""
 OUT="$REPO_ROOT/manifest/latest.json"
 CHANNEL="stable"
 COMPUTE_SHA256=0
+VSIX_URL=""
+
+# vsrocq language server, and the VSCode extension version matching it
+LANGUAGE_SERVER_VERSION="2.3.4"
+
+# opam release downloaded by the Linux GUI when opam is missing or too old
+OPAM_VERSION="2.3.0"
+OPAM_MIN_VERSION="2.1.0"
+OPAM_RELEASE_URL="https://github.com/ocaml/opam/releases/download/${OPAM_VERSION}"
 
 usage() {
   cat <<EOF
@@ -35,7 +44,8 @@ Required:
 Options:
   --out <path>           Output manifest file (default: manifest/latest.json)
   --channel <name>       Channel name (default: stable)
-  --compute-sha256       Download assets and compute sha256 hashes
+  --compute-sha256       Download assets (and pinned opam binaries) and compute sha256 hashes
+  --vsix-url <url>       .vsix of the VSCode extension, installed without Marketplace access
   -h, --help             Show this help message
 
 Examples:
@@ -118,6 +128,7 @@ while [[ $# -gt 0 ]]; do
     --out) OUT="${2:-}"; shift 2 ;;
     --channel) CHANNEL="${2:-}"; shift 2 ;;
     --compute-sha256) COMPUTE_SHA256=1; shift ;;
+    --vsix-url) VSIX_URL="${2:-}"; shift 2 ;;
     -h|--help) usage; exit 0 ;;
     *) echo "Unknown argument: $1" >&2; usage; exit 1 ;;
   esac
@@ -168,15 +179,37 @@ if [[ "$COMPUTE_SHA256" -eq 1 ]]; then
   done < <(echo "$assets" | jq -r '.[] | [.name, .url] | @tsv')
 fi
 
+opam_x86_64_url="${OPAM_RELEASE_URL}/opam-${OPAM_VERSION}-x86_64-linux"
+opam_arm64_url="${OPAM_RELEASE_URL}/opam-${OPAM_VERSION}-arm64-linux"
+opam_x86_64_sha=""
+opam_arm64_sha=""
+if [[ "$COMPUTE_SHA256" -eq 1 ]]; then
+  echo "Computing sha256 for opam ${OPAM_VERSION} binaries (downloading)..." >&2
+  curl -fL --retry 3 --retry-delay 1 -o "$tmpdir/opam-x86_64" "$opam_x86_64_url"
+  curl -fL --retry 3 --retry-delay 1 -o "$tmpdir/opam-arm64" "$opam_arm64_url"
+  opam_x86_64_sha="$(sha256_file "$tmpdir/opam-x86_64")"
+  opam_arm64_sha="$(sha256_file "$tmpdir/opam-arm64")"
+else
+  echo "WARNING: opam binaries written without sha256; the Linux installer refuses to download them. Use --compute-sha256." >&2
+fi
+
 # Build manifest skeleton with linux/opam default
 manifest="$(jq -n \
   --arg channel "$CHANNEL" \
   --arg platform_release "$platform_release" \
   --arg rocq_version "$rocq_version" \
+  --arg language_server_version "$LANGUAGE_SERVER_VERSION" \
+  --arg opam_version "$OPAM_VERSION" \
+  --arg opam_min_version "$OPAM_MIN_VERSION" \
+  --arg opam_x86_64_url "$opam_x86_64_url" \
+  --arg opam_x86_64_sha "$opam_x86_64_sha" \
+  --arg opam_arm64_url "$opam_arm64_url" \
+  --arg opam_arm64_sha "$opam_arm64_sha" \
   '{
     channel: $channel,
     platform_release: $platform_release,
     rocq_version: ($rocq_version // ""),
+    vscode_extension: { version: $language_server_version },
     assets: {
       macos: {},
       windows: {},
@@ -193,9 +226,17 @@ manifest="$(jq -n \
               { name: "rocq-core",              version: $rocq_version },
               { name: "rocq-stdlib",            version: $rocq_version },
               { name: "rocq-prover",            version: $rocq_version },
-              { name: "vsrocq-language-server", version: "2.3.4", optional: "skip_vscode" },
+              { name: "vsrocq-language-server", version: $language_server_version, optional: "skip_vscode" },
               { name: "rocqide",                version: $rocq_version, optional: "with_rocqide" }
-            ]
+            ],
+            opam_bootstrap: {
+              min_version: $opam_min_version,
+              version: $opam_version,
+              binaries: {
+                x86_64: { url: $opam_x86_64_url, sha256: $opam_x86_64_sha },
+                arm64:  { url: $opam_arm64_url,  sha256: $opam_arm64_sha }
+              }
+            }
           }
         }
       }
@@ -226,6 +267,21 @@ while IFS=$'\t' read -r name url; do
   )"
 done < <(echo "$assets" | jq -r '.[] | [.name, .url] | @tsv')
 
+# Pin the extension package, if any
+if [[ -n "$VSIX_URL" ]]; then
+  vsix_sha=""
+  if [[ "$COMPUTE_SHA256" -eq 1 ]]; then
+    echo "Computing sha256 for the extension package (downloading)..." >&2
+    curl -fL --retry 3 --retry-delay 1 -o "$tmpdir/extension.vsix" "$VSIX_URL"
+    vsix_sha="$(sha256_file "$tmpdir/extension.vsix")"
+  fi
+  manifest="$(echo "$manifest" | jq \
+    --arg url "$VSIX_URL" \
+    --arg sha "$vsix_sha" \
+    '.vscode_extension.vsix = {url: $url, sha256: $sha}'
+  )"
+fi
+
 # Write out
 echo "$manifest" | jq '.' > "$OUT"
 echo "Wrote manifest: $OUT" >&2
@@ -0,0 +1,47 @@
+package emacs
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Emacs packages set up by the installer, installed from MELPA.
+const (
+	ProofGeneral = "proof-general"
+	CompanyCoq   = "company-coq"
+)
+
+// libraries maps the packages to a library they provide.
+var libraries = map[string]string{
+	ProofGeneral: "proof-site",
+	CompanyCoq:   "company-coq",
+}
+
+// HasPackage reports whether Emacs finds the given package, installed with
+// package.el or in a site directory.
+func HasPackage(emacsBin, pkg string) bool {
+	expr := fmt.Sprintf(`(progn (package-initialize) (princ (or (locate-library %q) "")))`, libraries[pkg])
+	out, err := exec.Command(emacsBin, "--batch", "--eval", expr).Output()
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+// InstallPackage installs the given package from MELPA with package.el if
+// Emacs does not find it already.
+func InstallPackage(emacsBin, pkg string) error {
+	if HasPackage(emacsBin, pkg) {
+		return nil
+	}
+
+	expr := fmt.Sprintf(`(progn
+  (require 'package)
+  (add-to-list 'package-archives '("melpa" . "https://melpa.org/packages/") t)
+  (package-initialize)
+  (package-refresh-contents)
+  (package-install '%s))`, pkg)
+	output, err := exec.Command(emacsBin, "--batch", "--eval", expr).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("install %s: %w\nOutput: %s", pkg, err, string(output))
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
@@ -33,6 +34,16 @@ type InstallContext struct {
 	StepNames   []string
 
 	lastLoggedStep int
+	enableInputs   func() // makes the window usable again, see Abort
+}
+
+// Abort shows an error that stopped the installation, or kept it from
+// starting, and re-enables the inputs for another attempt.
+func (ctx *InstallContext) Abort(msg string) {
+	if ctx.enableInputs != nil {
+		ctx.enableInputs()
+	}
+	dialog.ShowError(fmt.Errorf("%s", msg), ctx.Window)
 }
 
 // OnStep updates the UI for a step transition.
@@ -70,6 +81,14 @@ func (ctx *InstallContext) OnStep(step int, label string, fraction float64) {
 	}
 }
 
+// FileAction is an additional way to start an installation from a file
+// chosen by the user, such as a lock file to reproduce.
+type FileAction struct {
+	Label      string   // button label, e.g. "Open lock file…"
+	Extensions []string // accepted file extensions, e.g. ".json"; empty accepts any file
+	Run        func(ctx *InstallContext, path string)
+}
+
 // AppConfig holds all the platform-specific callbacks and configuration
 // needed to run the shared GUI.
 type AppConfig struct {
@@ -94,12 +113,23 @@ type AppConfig struct {
 	ExistingDialogMsg string                   // dialog message shown when existing installations are found
 	NewInstallLabel   func() string            // label for the "install new" radio option
 
+	// Options are platform-specific settings shown under the release selector
+	Options []Option
+
 	// Doctor
 	RunDoctor func(onLog func(string))
 
 	// Install execution
 	RunInstall func(ctx *InstallContext, existingSelection string, skipInstall bool)
 
+	// FileActions add buttons starting an installation from a file
+	FileActions []*FileAction
+
+	// RelinkWorkspace binds a registered workspace to one of the
+	// installations returned by FindExisting. When set, a "Workspaces"
+	// button lists the registered workspaces.
+	RelinkWorkspace func(dir, target string) error
+
 	// ShowLog controls whether the log panel is visible (use --log flag)
 	ShowLog bool
 
@@ -179,6 +209,8 @@ func Run(cfg *AppConfig) {
 	releaseLabel := widget.NewLabelWithStyle("Release:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 	releaseRow := container.NewBorder(nil, nil, releaseLabel, nil, releaseSelect)
 
+	optionsSection, setOptionsEnabled := newOptionsSection(w, cfg.Options)
+
 	resolveTag := func(label string) string {
 		if tag, ok := labelToTag[label]; ok {
 			return tag
@@ -303,12 +335,28 @@ func Run(cfg *AppConfig) {
 	// --- Install button ---
 	installing := false
 	var installBtn *widget.Button
-	installBtn = widget.NewButtonWithIcon("Install", theme.DownloadIcon(), func() {
-		installing = true
-		installBtn.Disable()
-		releaseSelect.Disable()
+	var fileBtns []*widget.Button
+
+	// setInputsEnabled toggles the controls that must not change while an
+	// installation is running.
+	setInputsEnabled := func(enabled bool) {
+		for _, btn := range fileBtns {
+			if enabled {
+				btn.Enable()
+			} else {
+				btn.Disable()
+			}
+		}
+		if enabled {
+			releaseSelect.Enable()
+		} else {
+			releaseSelect.Disable()
+		}
+		setOptionsEnabled(enabled)
+	}
 
-		ctx := &InstallContext{
+	newInstallContext := func() *InstallContext {
+		return &InstallContext{
 			Window:      w,
 			StatusLabel: statusLabel,
 			ProgressBar: progressBar,
@@ -319,7 +367,20 @@ func Run(cfg *AppConfig) {
 			Checklist:   checklist,
 			TotalSteps:  totalSteps,
 			StepNames:   cfg.StepNames,
+			enableInputs: func() {
+				installing = false
+				installBtn.Enable()
+				setInputsEnabled(true)
+			},
 		}
+	}
+
+	installBtn = widget.NewButtonWithIcon("Install", theme.DownloadIcon(), func() {
+		installing = true
+		installBtn.Disable()
+		setInputsEnabled(false)
+
+		ctx := newInstallContext()
 
 		existingItems := cfg.FindExisting()
 		if len(existingItems) > 0 {
@@ -345,8 +406,7 @@ func Run(cfg *AppConfig) {
 
 			closeBtn.OnTapped = func() {
 				d.Hide()
-				installBtn.Enable()
-				releaseSelect.Enable()
+				ctx.enableInputs()
 			}
 			confirmBtn.OnTapped = func() {
 				d.Hide()
@@ -368,6 +428,31 @@ func Run(cfg *AppConfig) {
 	})
 	installBtn.Importance = widget.HighImportance
 
+	// --- File action buttons ---
+	for _, action := range cfg.FileActions {
+		action := action
+		btn := widget.NewButtonWithIcon(action.Label, theme.FolderOpenIcon(), func() {
+			d := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+				if err != nil || reader == nil {
+					return
+				}
+				path := reader.URI().Path()
+				reader.Close()
+
+				installing = true
+				installBtn.Disable()
+				setInputsEnabled(false)
+				logP.Append(fmt.Sprintf("Opening %s...", path))
+				go action.Run(newInstallContext(), path)
+			}, w)
+			if len(action.Extensions) > 0 {
+				d.SetFilter(storage.NewExtensionFileFilter(action.Extensions))
+			}
+			d.Show()
+		})
+		fileBtns = append(fileBtns, btn)
+	}
+
 	// --- Doctor button ---
 	var doctorBtn *widget.Button
 	doctorBtn = widget.NewButtonWithIcon("Doctor", theme.InfoIcon(), func() {
@@ -414,12 +499,37 @@ func Run(cfg *AppConfig) {
 	})
 	doctorBtn.Importance = widget.HighImportance
 
+	// --- Workspaces button ---
+	var workspacesBtn *widget.Button
+	if cfg.RelinkWorkspace != nil {
+		workspacesBtn = widget.NewButtonWithIcon("Workspaces", theme.FolderIcon(), func() {
+			workspacesBtn.Disable()
+			statusLabel.SetText("Looking for installations...")
+
+			go func() {
+				targets := cfg.FindExisting()
+				statusLabel.SetText("Ready to install")
+				showWorkspacesDialog(w, targets, cfg.RelinkWorkspace)
+				workspacesBtn.Enable()
+			}()
+		})
+	}
+
 	versionLabel := widget.NewLabelWithStyle("v"+cfg.Version, fyne.TextAlignTrailing, fyne.TextStyle{})
 	versionLabel.Importance = widget.LowImportance
 
+	bottomButtons := []fyne.CanvasObject{doctorBtn}
+	if workspacesBtn != nil {
+		bottomButtons = append(bottomButtons, workspacesBtn)
+	}
+	for _, btn := range fileBtns {
+		bottomButtons = append(bottomButtons, btn)
+	}
+	bottomButtons = append(bottomButtons, installBtn)
+
 	bottomBar := container.NewPadded(
 		container.NewBorder(nil, nil, nil, versionLabel,
-			container.NewCenter(container.NewHBox(doctorBtn, installBtn)),
+			container.NewCenter(container.NewHBox(bottomButtons...)),
 		),
 	)
 
@@ -430,6 +540,7 @@ func Run(cfg *AppConfig) {
 				header,
 				headerSep,
 				releaseRow,
+				optionsSection,
 				progressSection,
 			),
 			bottomBar,
@@ -72,12 +72,6 @@ func ShowVSCodeDialog(w fyne.Window) {
 	d.Show()
 }
 
-// ShowError re-enables the install button and shows an error dialog.
-func ShowError(w fyne.Window, installBtn *widget.Button, msg string) {
-	installBtn.Enable()
-	dialog.ShowError(fmt.Errorf("%s", msg), w)
-}
-
 // ShowSuccess displays a success dialog with the given message.
 func ShowSuccess(w fyne.Window, msg string) {
 	successMsg := widget.NewLabel(msg)
@@ -0,0 +1,141 @@
+package gui
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Option is a platform-specific setting shown under the release selector.
+// It is implemented by CheckOption, SelectOption and FolderOption.
+type Option interface {
+	build(w fyne.Window) fyne.CanvasObject
+	setEnabled(enabled bool)
+}
+
+// CheckOption is a boolean setting. Checked holds the current value and is
+// updated as the user toggles it.
+type CheckOption struct {
+	Label   string
+	Checked bool
+
+	check *widget.Check
+}
+
+func (o *CheckOption) build(w fyne.Window) fyne.CanvasObject {
+	o.check = widget.NewCheck(o.Label, func(checked bool) {
+		o.Checked = checked
+	})
+	o.check.SetChecked(o.Checked)
+	return o.check
+}
+
+func (o *CheckOption) setEnabled(enabled bool) {
+	if enabled {
+		o.check.Enable()
+	} else {
+		o.check.Disable()
+	}
+}
+
+// SelectOption is a choice among a fixed set of values, each shown with a
+// display name. Selected holds the current value and is updated as the user
+// picks another entry.
+type SelectOption struct {
+	Label    string
+	Selected string
+
+	values []string
+	names  []string
+	sel    *widget.Select
+}
+
+// Add appends a choice with the given value and display name.
+func (o *SelectOption) Add(value, name string) {
+	o.values = append(o.values, value)
+	o.names = append(o.names, name)
+}
+
+func (o *SelectOption) build(w fyne.Window) fyne.CanvasObject {
+	o.sel = widget.NewSelect(o.names, func(name string) {
+		for i, n := range o.names {
+			if n == name {
+				o.Selected = o.values[i]
+			}
+		}
+	})
+	for i, v := range o.values {
+		if v == o.Selected {
+			o.sel.SetSelected(o.names[i])
+		}
+	}
+
+	label := widget.NewLabelWithStyle(o.Label, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	return container.NewBorder(nil, nil, label, nil, o.sel)
+}
+
+func (o *SelectOption) setEnabled(enabled bool) {
+	if enabled {
+		o.sel.Enable()
+	} else {
+		o.sel.Disable()
+	}
+}
+
+// FolderOption is a directory path, typed in or picked with a folder dialog.
+// Path holds the current value and is updated as the user edits it.
+type FolderOption struct {
+	Label string
+	Path  string
+
+	entry  *widget.Entry
+	browse *widget.Button
+}
+
+func (o *FolderOption) build(w fyne.Window) fyne.CanvasObject {
+	o.entry = widget.NewEntry()
+	o.entry.SetText(o.Path)
+	o.entry.OnChanged = func(text string) {
+		o.Path = text
+	}
+
+	o.browse = widget.NewButtonWithIcon("Browse\u2026", theme.FolderOpenIcon(), func() {
+		dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err != nil || dir == nil {
+				return
+			}
+			o.entry.SetText(dir.Path())
+		}, w)
+	})
+
+	label := widget.NewLabelWithStyle(o.Label, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	return container.NewBorder(nil, nil, label, o.browse, o.entry)
+}
+
+func (o *FolderOption) setEnabled(enabled bool) {
+	if enabled {
+		o.entry.Enable()
+		o.browse.Enable()
+	} else {
+		o.entry.Disable()
+		o.browse.Disable()
+	}
+}
+
+// newOptionsSection builds the widgets for the given options. It returns the
+// container to lay out and a function enabling or disabling all of them.
+func newOptionsSection(w fyne.Window, options []Option) (fyne.CanvasObject, func(enabled bool)) {
+	box := container.NewVBox()
+	for _, opt := range options {
+		box.Add(opt.build(w))
+	}
+
+	setEnabled := func(enabled bool) {
+		for _, opt := range options {
+			opt.setEnabled(enabled)
+		}
+	}
+	return box, setEnabled
+}
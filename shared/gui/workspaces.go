@@ -0,0 +1,98 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/justme0606/rocq-bootstrap/shared/registry"
+)
+
+// showWorkspacesDialog lists the registered workspaces with their
+// environment, and lets the user relink one to another installation from
+// targets or forget it.
+func showWorkspacesDialog(w fyne.Window, targets []string, relink func(dir, target string) error) {
+	list := container.NewVBox()
+	scroll := container.NewScroll(list)
+	scroll.SetMinSize(fyne.NewSize(560, 350))
+
+	var refresh func()
+	refresh = func() {
+		list.RemoveAll()
+		reg, err := registry.Load()
+		if err != nil {
+			list.Add(widget.NewLabel(err.Error()))
+			return
+		}
+		if len(reg.Workspaces) == 0 {
+			list.Add(widget.NewLabel("No workspace has been registered yet."))
+			return
+		}
+
+		for _, e := range reg.Workspaces {
+			e := e
+			title := widget.NewLabelWithStyle(e.Dir, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			info := fmt.Sprintf("→ %s", e.Environment())
+			if e.PlatformRelease != "" {
+				info += fmt.Sprintf(" (platform %s, Rocq %s)", e.PlatformRelease, e.RocqVersion)
+			}
+			if problems := e.Problems(); len(problems) > 0 {
+				info += "\n⚠ " + strings.Join(problems, "\n⚠ ")
+			}
+			details := widget.NewLabel(info)
+			details.Wrapping = fyne.TextWrapWord
+
+			target := widget.NewSelect(targets, nil)
+			target.PlaceHolder = "Relink to…"
+			relinkBtn := widget.NewButton("Relink", func() {
+				if target.Selected == "" {
+					return
+				}
+				if err := relink(e.Dir, target.Selected); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+				refresh()
+			})
+			forgetBtn := widget.NewButton("Forget", func() {
+				reg.Forget(e.Dir)
+				if err := reg.Save(); err != nil {
+					dialog.ShowError(err, w)
+				}
+				refresh()
+			})
+
+			actions := container.NewBorder(nil, nil, nil, container.NewHBox(relinkBtn, forgetBtn), target)
+			list.Add(container.NewVBox(title, details, actions, widget.NewSeparator()))
+		}
+	}
+	refresh()
+
+	forgetMissingBtn := widget.NewButton("Forget missing", func() {
+		reg, err := registry.Load()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		if len(reg.ForgetMissing()) > 0 {
+			if err := reg.Save(); err != nil {
+				dialog.ShowError(err, w)
+			}
+		}
+		refresh()
+	})
+	closeBtn := widget.NewButton("Close", nil)
+	closeBtn.Importance = widget.HighImportance
+
+	buttons := container.NewCenter(container.NewHBox(forgetMissingBtn, closeBtn))
+	content := container.NewBorder(nil, buttons, nil, nil, scroll)
+	d := dialog.NewCustomWithoutButtons("Workspaces", content, w)
+	closeBtn.OnTapped = func() {
+		d.Hide()
+	}
+	d.Show()
+}
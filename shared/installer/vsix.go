@@ -0,0 +1,28 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FetchVSIX downloads the VSCode extension package at url into destDir and
+// verifies its SHA256, which must be given. Returns the path to the package.
+func FetchVSIX(url, sha256, destDir string, logger *Logger) (string, error) {
+	// VerifySHA256 accepts any file when no checksum is given: never
+	// install an unverified extension.
+	if strings.TrimSpace(sha256) == "" {
+		return "", fmt.Errorf("no sha256 pinned in manifest for the extension package %s", url)
+	}
+
+	logger.Log("Downloading extension package %s", url)
+	path, err := Download(url, destDir, "extension.vsix", nil)
+	if err != nil {
+		return "", fmt.Errorf("download vsix: %w", err)
+	}
+
+	logger.Log("Verifying extension package SHA256 (expected: %q)", sha256)
+	if err := VerifySHA256(path, sha256); err != nil {
+		return "", fmt.Errorf("vsix checksum: %w", err)
+	}
+	return path, nil
+}
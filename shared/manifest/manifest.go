@@ -13,6 +13,18 @@ type Base struct {
 	PlatformRelease string `json:"platform_release"`
 }
 
+// VSCodeExtension pins the VSCode extension the installers set up.
+type VSCodeExtension struct {
+	Version string `json:"version,omitempty"` // version to install, matching the language server; empty means the latest
+	VSIX    *VSIX  `json:"vsix,omitempty"`    // package installed from disk instead of the Marketplace
+}
+
+// VSIX is a downloadable package of the VSCode extension.
+type VSIX struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
 // Load reads a manifest file from an embedded filesystem, unmarshals it
 // using the provided parse function, and returns the result.
 func Load[T any](fsys fs.FS, path string, parse func([]byte) (*T, error)) (*T, error) {
@@ -0,0 +1,180 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry records a workspace created or configured by rocq-bootstrap and
+// the environment it is bound to.
+type Entry struct {
+	Dir             string    `json:"dir"`
+	PlatformRelease string    `json:"platform_release,omitempty"`
+	RocqVersion     string    `json:"rocq_version,omitempty"`
+	Switch          string    `json:"switch,omitempty"`      // opam switch (Linux)
+	OpamRoot        string    `json:"opam_root,omitempty"`   // opam root; empty means the default root
+	InstallDir      string    `json:"install_dir,omitempty"` // Rocq Platform install directory or .app (macOS, Windows)
+	LanguageServer  string    `json:"language_server,omitempty"`
+	Profile         string    `json:"vscode_profile,omitempty"` // VSCode profile the workspace opens in; empty means the default profile
+	UserSettings    string    `json:"user_settings,omitempty"`  // VSCode user settings file holding the language server path (workspace.ScopeUser); empty when the workspace settings do
+	Updated         time.Time `json:"updated"`
+}
+
+// Environment returns a short description of what the workspace is bound to.
+func (e *Entry) Environment() string {
+	switch {
+	case e.Switch != "" && e.OpamRoot != "":
+		return fmt.Sprintf("%s (%s)", e.Switch, e.OpamRoot)
+	case e.Switch != "":
+		return e.Switch
+	case e.InstallDir != "":
+		return e.InstallDir
+	}
+	return "(unknown)"
+}
+
+// Problems returns what is wrong with the entry: a workspace or an
+// environment that no longer exists, or a language server that is gone.
+func (e *Entry) Problems() []string {
+	var problems []string
+	if info, err := os.Stat(e.Dir); err != nil || !info.IsDir() {
+		problems = append(problems, "workspace directory not found")
+	}
+	if e.OpamRoot != "" {
+		if _, err := os.Stat(e.OpamRoot); err != nil {
+			problems = append(problems, fmt.Sprintf("opam root %s not found", e.OpamRoot))
+		}
+	}
+	if e.InstallDir != "" {
+		if _, err := os.Stat(e.InstallDir); err != nil {
+			problems = append(problems, fmt.Sprintf("%s not found", e.InstallDir))
+		}
+	}
+	if e.LanguageServer != "" {
+		if _, err := os.Stat(e.LanguageServer); err != nil {
+			problems = append(problems, fmt.Sprintf("language server %s not found", e.LanguageServer))
+		}
+	}
+	return problems
+}
+
+// Registry lists the managed workspaces, stored in
+// ~/.rocq-setup/workspaces.json.
+type Registry struct {
+	Workspaces []*Entry `json:"workspaces"`
+}
+
+// path returns the location of the registry file.
+func path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home dir: %w", err)
+	}
+	return filepath.Join(home, ".rocq-setup", "workspaces.json"), nil
+}
+
+// Load reads the registry. A missing file yields an empty registry.
+func Load() (*Registry, error) {
+	r := &Registry{}
+	p, err := path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read workspace registry: %w", err)
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", p, err)
+	}
+	return r, nil
+}
+
+// Save writes the registry file.
+func (r *Registry) Save() error {
+	p, err := path()
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode workspace registry: %w", err)
+	}
+	content = append(content, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(p), err)
+	}
+	if err := os.WriteFile(p, content, 0o644); err != nil {
+		return fmt.Errorf("write workspace registry: %w", err)
+	}
+	return nil
+}
+
+// Find returns the entry for the workspace dir, or nil.
+func (r *Registry) Find(dir string) *Entry {
+	dir = filepath.Clean(dir)
+	for _, e := range r.Workspaces {
+		if e.Dir == dir {
+			return e
+		}
+	}
+	return nil
+}
+
+// Put adds e, replacing any entry for the same workspace.
+func (r *Registry) Put(e *Entry) {
+	e.Dir = filepath.Clean(e.Dir)
+	e.Updated = time.Now().UTC().Truncate(time.Second)
+	for i, old := range r.Workspaces {
+		if old.Dir == e.Dir {
+			r.Workspaces[i] = e
+			return
+		}
+	}
+	r.Workspaces = append(r.Workspaces, e)
+}
+
+// Forget removes the entry for the workspace dir and reports whether
+// there was one.
+func (r *Registry) Forget(dir string) bool {
+	dir = filepath.Clean(dir)
+	for i, e := range r.Workspaces {
+		if e.Dir == dir {
+			r.Workspaces = append(r.Workspaces[:i], r.Workspaces[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ForgetMissing removes the entries whose workspace directory no longer
+// exists and returns them.
+func (r *Registry) ForgetMissing() []*Entry {
+	var kept, removed []*Entry
+	for _, e := range r.Workspaces {
+		if info, err := os.Stat(e.Dir); err != nil || !info.IsDir() {
+			removed = append(removed, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	r.Workspaces = kept
+	return removed
+}
+
+// Register records e in the registry file.
+func Register(e *Entry) error {
+	r, err := Load()
+	if err != nil {
+		return err
+	}
+	r.Put(e)
+	return r.Save()
+}
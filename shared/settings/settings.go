@@ -0,0 +1,118 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/justme0606/rocq-bootstrap/shared/vscode"
+	"github.com/justme0606/rocq-bootstrap/shared/workspace"
+)
+
+// Settings are the user's choices remembered between runs, and the
+// changes to restore on uninstall, stored in ~/.rocq-setup/settings.json.
+type Settings struct {
+	WorkspaceDir      string                    `json:"workspace_dir,omitempty"`
+	RemovedExtensions []vscode.RemovedExtension `json:"removed_extensions,omitempty"`
+}
+
+// path returns the location of the settings file.
+func path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home dir: %w", err)
+	}
+	return filepath.Join(home, ".rocq-setup", "settings.json"), nil
+}
+
+// Load returns the saved settings. A missing or unreadable file yields
+// empty settings so callers fall back to the defaults.
+func Load() *Settings {
+	s := &Settings{}
+	p, err := path()
+	if err != nil {
+		return s
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return s
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		log.Printf("[settings] ignoring invalid %s: %v", p, err)
+		return &Settings{}
+	}
+	return s
+}
+
+// Save writes the settings file.
+func (s *Settings) Save() error {
+	p, err := path()
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode settings: %w", err)
+	}
+	content = append(content, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(p), err)
+	}
+	if err := os.WriteFile(p, content, 0o644); err != nil {
+		return fmt.Errorf("write settings: %w", err)
+	}
+	return nil
+}
+
+// Workspace returns the workspace directory to use: the remembered one, or
+// the default ~/rocq-workspace.
+func (s *Settings) Workspace() (string, error) {
+	return workspace.ResolveDir(s.WorkspaceDir)
+}
+
+// RememberWorkspace saves dir as the workspace directory for the next runs.
+func RememberWorkspace(dir string) error {
+	s := Load()
+	if s.WorkspaceDir == dir {
+		return nil
+	}
+	s.WorkspaceDir = dir
+	return s.Save()
+}
+
+// RecordRemovedExtension saves that the installer uninstalled r, to be
+// restored by RestoreExtensions.
+func RecordRemovedExtension(r vscode.RemovedExtension) error {
+	s := Load()
+	s.RemovedExtensions = append(s.RemovedExtensions, r)
+	return s.Save()
+}
+
+// RestoreExtensions installs again the extensions the installer
+// uninstalled and forgets them. Extensions that cannot be restored stay
+// recorded for the next attempt; their errors are joined.
+func RestoreExtensions() ([]vscode.RemovedExtension, error) {
+	s := Load()
+	var restored, left []vscode.RemovedExtension
+	var errs []error
+	for _, r := range s.RemovedExtensions {
+		if err := r.Restore(); err != nil {
+			left = append(left, r)
+			errs = append(errs, err)
+			continue
+		}
+		restored = append(restored, r)
+	}
+	if len(restored) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	s.RemovedExtensions = left
+	if err := s.Save(); err != nil {
+		errs = append(errs, err)
+	}
+	return restored, errors.Join(errs...)
+}
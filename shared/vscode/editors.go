@@ -0,0 +1,170 @@
+package vscode
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Flavor is a VSCode-family editor product.
+type Flavor struct {
+	Name      string // product name
+	Command   string // command line interface on PATH
+	OpenVSX   bool   // gets extensions from Open VSX rather than the Visual Studio Marketplace
+	ConfigDir string // configuration directory, under the user configuration directory
+	ServerDir string // directory of its server in the home directory of a remote (WSL)
+}
+
+// Flavors lists the VSCode-family editors the installers look for, in
+// order of preference.
+var Flavors = []Flavor{
+	{Name: "VSCode", Command: "code", ConfigDir: "Code", ServerDir: ".vscode-server"},
+	{Name: "VSCode Insiders", Command: "code-insiders", ConfigDir: "Code - Insiders", ServerDir: ".vscode-server-insiders"},
+	{Name: "VSCodium", Command: "codium", OpenVSX: true, ConfigDir: "VSCodium", ServerDir: ".vscodium-server"},
+	{Name: "Cursor", Command: "cursor", OpenVSX: true, ConfigDir: "Cursor", ServerDir: ".cursor-server"},
+}
+
+// Sandboxes an editor may be confined in on Linux.
+const (
+	SandboxFlatpak = "flatpak"
+	SandboxSnap    = "snap" // strictly confined snap; classic snaps are not sandboxed
+)
+
+// RemoteWSL marks a Windows editor run from WSL through its shim, which
+// installs extensions in WSL and opens folders through Remote-WSL.
+const RemoteWSL = "wsl"
+
+// Editor is an installed VSCode-family editor.
+type Editor struct {
+	Flavor
+	Bin     string // command line interface, passed as codeBin
+	Sandbox string // SandboxFlatpak or SandboxSnap when sandboxed; empty otherwise
+	Remote  string // RemoteWSL for a Windows editor used from WSL; empty otherwise
+
+	// ConfigRoot holds the ConfigDir of a sandboxed editor; empty means
+	// the user configuration directory (os.UserConfigDir).
+	ConfigRoot string
+}
+
+// Label returns the name of the editor shown to the user.
+func (e *Editor) Label() string {
+	if e.Remote == RemoteWSL {
+		return e.Name + " (Windows, Remote-WSL)"
+	}
+	switch e.Sandbox {
+	case SandboxFlatpak:
+		return e.Name + " (Flatpak)"
+	case SandboxSnap:
+		return e.Name + " (Snap)"
+	}
+	return e.Name
+}
+
+// UserSettingsPath returns the user settings file of the editor for the
+// profile ("" for the default one). For a Windows editor used from WSL, it
+// is the settings file of the WSL machine, which applies to the folders
+// opened there whatever the profile.
+func (e *Editor) UserSettingsPath(profile string) (string, error) {
+	if e.Remote == RemoteWSL {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("get home dir: %w", err)
+		}
+		return filepath.Join(home, e.ServerDir, "data", "Machine", "settings.json"), nil
+	}
+	root := e.ConfigRoot
+	if root == "" {
+		var err error
+		if root, err = os.UserConfigDir(); err != nil {
+			return "", fmt.Errorf("get config dir: %w", err)
+		}
+	}
+	userDir := filepath.Join(root, e.ConfigDir, "User")
+	if profile == "" {
+		return filepath.Join(userDir, "settings.json"), nil
+	}
+	dir, err := profileDir(userDir, profile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "settings.json"), nil
+}
+
+// profileDir returns the directory of the named profile, as recorded by the
+// editor in globalStorage/storage.json of its User directory: a directory
+// name under profiles/, or a file URI.
+func profileDir(userDir, profile string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(userDir, "globalStorage", "storage.json"))
+	if err != nil {
+		return "", fmt.Errorf("VSCode profile %q: %w", profile, err)
+	}
+	var storage struct {
+		Profiles []struct {
+			Name     string          `json:"name"`
+			Location json.RawMessage `json:"location"`
+		} `json:"userDataProfiles"`
+	}
+	if err := json.Unmarshal(data, &storage); err != nil {
+		return "", fmt.Errorf("parse storage.json: %w", err)
+	}
+	for _, p := range storage.Profiles {
+		if p.Name != profile {
+			continue
+		}
+		var name string
+		if err := json.Unmarshal(p.Location, &name); err == nil && name != "" {
+			return filepath.Join(userDir, "profiles", name), nil
+		}
+		var uri struct {
+			Scheme string `json:"scheme"`
+			Path   string `json:"path"`
+		}
+		if err := json.Unmarshal(p.Location, &uri); err == nil && uri.Scheme == "file" && uri.Path != "" {
+			path := uri.Path
+			// Windows paths are /c:/Users/...
+			if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+				path = path[1:]
+			}
+			return filepath.FromSlash(path), nil
+		}
+		return "", fmt.Errorf("VSCode profile %q: unsupported location %s", profile, p.Location)
+	}
+	return "", fmt.Errorf("VSCode profile %q not found", profile)
+}
+
+// Marketplace returns the name of the extension source of the editor.
+func (e *Editor) Marketplace() string {
+	if e.OpenVSX {
+		return "Open VSX"
+	}
+	return "the Visual Studio Marketplace"
+}
+
+// SelectEditor returns the editor of editors that choice names, by its
+// command line interface (path or base name) or its label, or the first
+// one when choice is empty.
+func SelectEditor(editors []Editor, choice string) (*Editor, error) {
+	if len(editors) == 0 {
+		return nil, fmt.Errorf("no VSCode-family editor (%s) found in PATH or common locations", flavorCommands())
+	}
+	if choice == "" {
+		return &editors[0], nil
+	}
+	for i, e := range editors {
+		if e.Bin == choice || filepath.Base(e.Bin) == choice || strings.EqualFold(e.Label(), choice) {
+			return &editors[i], nil
+		}
+	}
+	return nil, fmt.Errorf("VSCode-family editor %q not found", choice)
+}
+
+// flavorCommands returns the commands of Flavors, for error messages.
+func flavorCommands() string {
+	commands := make([]string, len(Flavors))
+	for i, f := range Flavors {
+		commands[i] = f.Command
+	}
+	return strings.Join(commands, ", ")
+}
@@ -0,0 +1,61 @@
+package vscode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUserSettingsPath(t *testing.T) {
+	root := t.TempDir()
+	user := filepath.Join(root, "Code", "User")
+	storage := filepath.Join(user, "globalStorage", "storage.json")
+	if err := os.MkdirAll(filepath.Dir(storage), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	const data = `{
+	"userDataProfiles": [
+		{"location": "-5a1b2c3d", "name": "Rocq", "icon": "beaker"},
+		{"location": {"$mid": 1, "scheme": "file", "path": "/elsewhere/rocq"}, "name": "Elsewhere"},
+		{"location": {"scheme": "vscode-remote", "path": "/x"}, "name": "Remote"}
+	]
+}`
+	if err := os.WriteFile(storage, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := &Editor{Flavor: Flavors[0], ConfigRoot: root}
+
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{"", filepath.Join(user, "settings.json")},
+		{"Rocq", filepath.Join(user, "profiles", "-5a1b2c3d", "settings.json")},
+		{"Elsewhere", filepath.Join(filepath.FromSlash("/elsewhere/rocq"), "settings.json")},
+	}
+	for _, tt := range tests {
+		got, err := e.UserSettingsPath(tt.profile)
+		if err != nil {
+			t.Errorf("UserSettingsPath(%q): %v", tt.profile, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("UserSettingsPath(%q) = %q, want %q", tt.profile, got, tt.want)
+		}
+	}
+
+	for _, profile := range []string{"Missing", "Remote"} {
+		if got, err := e.UserSettingsPath(profile); err == nil {
+			t.Errorf("UserSettingsPath(%q) = %q, want an error", profile, got)
+		}
+	}
+}
+
+// TestUserSettingsPathNoStorage checks that a profile is not found when
+// the editor has never run.
+func TestUserSettingsPathNoStorage(t *testing.T) {
+	e := &Editor{Flavor: Flavors[0], ConfigRoot: t.TempDir()}
+	if got, err := e.UserSettingsPath("Rocq"); err == nil {
+		t.Errorf("UserSettingsPath = %q, want an error", got)
+	}
+}
@@ -1,8 +1,11 @@
 package vscode
 
 import (
+	"bufio"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -12,6 +15,19 @@ const (
 	CoqExtensionID  = "coq-community.vscoq"
 )
 
+// DefaultProfile is the name of the VSCode profile the installers set up
+// for Rocq when asked for a dedicated one.
+const DefaultProfile = "Rocq"
+
+// command returns the command running the editor CLI with args in the
+// given profile; an empty profile means the default one.
+func command(codeBin, profile string, args ...string) *exec.Cmd {
+	if profile != "" {
+		args = append([]string{"--profile", profile}, args...)
+	}
+	return exec.Command(codeBin, args...)
+}
+
 // IsCoq returns true if the version refers to a Coq release (major version < 9).
 func IsCoq(version string) bool {
 	parts := strings.SplitN(version, ".", 2)
@@ -31,10 +47,20 @@ func ExtensionIDForVersion(rocqVersion string) string {
 	return RocqExtensionID
 }
 
-// InstallExtension installs the given VSCode extension if not already present.
-func InstallExtension(codeBin, extensionID string) error {
+// ConflictingExtensionID returns the extension that must not be enabled
+// together with extensionID: VSCoq for VSRocq, and VSRocq for VSCoq.
+func ConflictingExtensionID(extensionID string) string {
+	if extensionID == CoqExtensionID {
+		return RocqExtensionID
+	}
+	return CoqExtensionID
+}
+
+// InstallExtension installs the given VSCode extension in the profile if
+// not already present.
+func InstallExtension(codeBin, profile, extensionID string) error {
 	// Check if already installed
-	out, err := exec.Command(codeBin, "--list-extensions").Output()
+	out, err := command(codeBin, profile, "--list-extensions").Output()
 	if err == nil {
 		for _, line := range strings.Split(string(out), "\n") {
 			if strings.EqualFold(strings.TrimSpace(line), extensionID) {
@@ -43,7 +69,7 @@ func InstallExtension(codeBin, extensionID string) error {
 		}
 	}
 
-	cmd := exec.Command(codeBin, "--install-extension", extensionID)
+	cmd := command(codeBin, profile, "--install-extension", extensionID)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("install extension: %w\nOutput: %s", err, string(output))
@@ -52,8 +78,145 @@ func InstallExtension(codeBin, extensionID string) error {
 	return nil
 }
 
-// OpenWorkspace opens VSCode with the given workspace directory.
-func OpenWorkspace(codeBin, workspaceDir string) error {
-	cmd := exec.Command(codeBin, workspaceDir)
+// InstallVSIX installs the extension packaged in the .vsix file at path in
+// the profile, replacing any installed version, without Marketplace access.
+func InstallVSIX(codeBin, profile, path string) error {
+	cmd := command(codeBin, profile, "--install-extension", path, "--force")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("install extension from %s: %w\nOutput: %s", path, err, string(output))
+	}
+	return nil
+}
+
+// InstallExtensionVersion installs a specific version of the given extension
+// in the profile, replacing any other installed version.
+func InstallExtensionVersion(codeBin, profile, extensionID, version string) error {
+	cmd := command(codeBin, profile, "--install-extension", extensionID+"@"+version, "--force")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("install extension %s@%s: %w\nOutput: %s", extensionID, version, err, string(output))
+	}
+	return nil
+}
+
+// EnsureExtensionVersion installs the given version of the extension
+// unless it is the installed one, replacing any other version. It returns
+// the version that was installed before, "" if none.
+func EnsureExtensionVersion(codeBin, profile, extensionID, version string) (string, error) {
+	installed, err := InstalledExtensionVersion(codeBin, profile, extensionID)
+	if err != nil {
+		return "", err
+	}
+	if installed == version {
+		return installed, nil
+	}
+	return installed, InstallExtensionVersion(codeBin, profile, extensionID, version)
+}
+
+// InstalledExtensionVersion returns the version of the given extension
+// installed in the profile, or "" if it is not installed.
+func InstalledExtensionVersion(codeBin, profile, extensionID string) (string, error) {
+	out, err := command(codeBin, profile, "--list-extensions", "--show-versions").Output()
+	if err != nil {
+		return "", fmt.Errorf("list extensions: %w", err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		id, version, ok := strings.Cut(strings.TrimSpace(line), "@")
+		if ok && strings.EqualFold(id, extensionID) {
+			return version, nil
+		}
+	}
+	return "", nil
+}
+
+// OpenWorkspace opens VSCode with the given workspace directory in the
+// profile, which VSCode creates if needed and associates with the
+// workspace, disabling the given extensions in its window.
+func OpenWorkspace(codeBin, profile, workspaceDir string, disabled ...string) error {
+	var args []string
+	for _, id := range disabled {
+		args = append(args, "--disable-extension", id)
+	}
+	cmd := command(codeBin, profile, append(args, workspaceDir)...)
 	return cmd.Start()
 }
+
+// UninstallExtension removes the given extension from the profile.
+func UninstallExtension(codeBin, profile, extensionID string) error {
+	cmd := command(codeBin, profile, "--uninstall-extension", extensionID)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("uninstall extension %s: %w\nOutput: %s", extensionID, err, string(output))
+	}
+	return nil
+}
+
+// RemovedExtension is an extension the installer uninstalled because it
+// conflicts with the one it set up, recorded to be restored later.
+type RemovedExtension struct {
+	Editor  string `json:"editor"`            // CLI of the editor it was removed from
+	Profile string `json:"profile,omitempty"` // profile it was removed from; empty means the default one
+	ID      string `json:"id"`
+	Version string `json:"version,omitempty"`
+}
+
+// Restore installs the removed extension again, in its previous version
+// when known.
+func (r *RemovedExtension) Restore() error {
+	if r.Version != "" {
+		return InstallExtensionVersion(r.Editor, r.Profile, r.ID, r.Version)
+	}
+	return InstallExtension(r.Editor, r.Profile, r.ID)
+}
+
+// languageServerPackages are the opam packages of the language servers of
+// the extensions.
+var languageServerPackages = []string{"vsrocq-language-server", "vscoq-language-server"}
+
+// LanguageServerVersion returns the version of the language server at
+// topPath, read from the findlib META file its package installs next to
+// it (<prefix>/lib/<package>/META), or "" if it cannot be found.
+func LanguageServerVersion(topPath string) string {
+	prefix := filepath.Dir(filepath.Dir(topPath))
+	for _, pkg := range languageServerPackages {
+		if version := metaVersion(filepath.Join(prefix, "lib", pkg, "META")); version != "" {
+			return version
+		}
+	}
+	return ""
+}
+
+// metaVersion returns the version field of the findlib META file at path,
+// or "" if it cannot be read.
+func metaVersion(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		key, value, ok := strings.Cut(s.Text(), "=")
+		if ok && strings.TrimSpace(key) == "version" {
+			return strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return ""
+}
+
+// CompatibleVersions reports whether the extension (client) version works
+// with the language server version: VsRocq releases both together, and a
+// client talks to the servers of its own major.minor release.
+func CompatibleVersions(client, server string) bool {
+	return majorMinor(client) == majorMinor(server)
+}
+
+// majorMinor returns the major.minor part of version.
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
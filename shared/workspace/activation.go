@@ -0,0 +1,287 @@
+package workspace
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ActivationEnv describes the environment enabled by the activation
+// scripts: an opam switch (Linux), or the directories of a Rocq Platform
+// installation to put on PATH (macOS, Windows).
+type ActivationEnv struct {
+	SwitchName string   // opam switch; empty when there is none
+	OpamBin    string   // opam executable; empty means opam from PATH
+	OpamRoot   string   // opam root; empty means opam's default root
+	Path       []string // directories prepended to PATH
+	Direnv     bool     // also write a .envrc for direnv
+	GOOS       string   // system the scripts are for; empty means runtime.GOOS
+}
+
+// pathDirs returns the directories the scripts prepend to PATH.
+func (e *ActivationEnv) pathDirs() []string {
+	if e.OpamBin == "" {
+		return e.Path
+	}
+	return append(append([]string{}, e.Path...), filepath.Dir(e.OpamBin))
+}
+
+// description names the environment in the scripts' messages.
+func (e *ActivationEnv) description() string {
+	if e.SwitchName != "" {
+		return "switch: " + e.SwitchName
+	}
+	return strings.Join(e.Path, ", ")
+}
+
+// dialects returns the activation scripts written for the target system.
+func (e *ActivationEnv) dialects() []*shellDialect {
+	goos := e.GOOS
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	switch goos {
+	case "darwin":
+		return []*shellDialect{shDialect, zshDialect}
+	case "windows":
+		return []*shellDialect{pwshDialect, batDialect}
+	}
+	return []*shellDialect{shDialect, fishDialect, nuDialect, pwshDialect}
+}
+
+// shellDialect is the syntax of one activation script. Each format takes
+// values already quoted with quote.
+type shellDialect struct {
+	file    string
+	usage   string // how to load the script, shown in its header
+	shebang string // first line, e.g. "#!/usr/bin/env bash\n"
+	comment string // comment leader; empty means "#"
+	quote   func(string) string
+	path    string // prepends a directory to PATH
+	setenv  string // exports a variable: name, value
+	opamEnv string // loads the switch environment: opam command, switch
+	after   string // lines run once the environment is loaded
+	echo    string // prints a message
+}
+
+// The activation scripts written by WriteActivationScripts, all loading
+// the same ActivationEnv.
+var (
+	// Sourced by bash and zsh; zsh caches command locations.
+	shDialect = &shellDialect{
+		file:    "activate.sh",
+		usage:   "source activate.sh (bash, zsh)",
+		shebang: "#!/usr/bin/env bash\n",
+		quote:   shellQuote,
+		path:    `export PATH=%s:"$PATH"`,
+		setenv:  "export %s=%s",
+		opamEnv: `eval "$(%s env --switch=%s --set-switch --shell=sh)"`,
+		after:   `if [ -n "${ZSH_VERSION:-}" ]; then rehash; else hash -r; fi`,
+		echo:    "echo %s",
+	}
+	zshDialect = &shellDialect{
+		file:    "activate.zsh",
+		usage:   "source activate.zsh",
+		quote:   shellQuote,
+		path:    `export PATH=%s:"$PATH"`,
+		setenv:  "export %s=%s",
+		opamEnv: `eval "$(%s env --switch=%s --set-switch --shell=zsh)"`,
+		after:   "rehash",
+		echo:    "echo %s",
+	}
+	fishDialect = &shellDialect{
+		file:    "activate.fish",
+		usage:   "source activate.fish",
+		quote:   fishQuote,
+		path:    "set -gx PATH %s $PATH",
+		setenv:  "set -gx %s %s",
+		opamEnv: "%s env --switch=%s --set-switch --shell=fish | source",
+		echo:    "echo %s",
+	}
+	// opam has no nushell output: load the variables opam exec sets.
+	nuDialect = &shellDialect{
+		file:    "activate.nu",
+		usage:   "source activate.nu",
+		quote:   nuQuote,
+		path:    "$env.PATH = ($env.PATH | split row (char esep) | prepend %s)",
+		setenv:  "$env.%s = %s",
+		opamEnv: `load-env (^%s exec --switch=%s -- env | lines | parse "{name}={value}" | where name =~ '^(PATH|MANPATH|OPAM|CAML_LD_LIBRARY_PATH|OCAML)' | transpose --ignore-titles --header-row --as-record)`,
+		after:   "$env.PATH = ($env.PATH | split row (char esep))",
+		echo:    "print %s",
+	}
+	// opam env --shell=pwsh needs opam 2.2: set the variables of the sh
+	// output (NAME='value'; export NAME;) instead.
+	pwshDialect = &shellDialect{
+		file:    "activate.ps1",
+		usage:   ". ./activate.ps1 (PowerShell)",
+		quote:   pwshQuote,
+		path:    "$env:PATH = %s + [IO.Path]::PathSeparator + $env:PATH",
+		setenv:  "$env:%s = %s",
+		opamEnv: `& %s env --switch %s --set-switch --shell=sh | ForEach-Object { if ($_ -match "^(\w+)='(.*)'; export \w+;$") { Set-Item "env:$($Matches[1])" ($Matches[2] -replace "'\\''", "'") } }`,
+		echo:    "Write-Host %s",
+	}
+	batDialect = &shellDialect{
+		file:    "activate.bat",
+		usage:   "call activate.bat (cmd.exe)",
+		shebang: "@echo off\r\n",
+		comment: "REM",
+		quote:   batQuote,
+		path:    `set "PATH=%s;%%PATH%%"`,
+		setenv:  `set "%s=%s"`,
+		echo:    "echo %s",
+	}
+)
+
+// direnvDialect is the .envrc loaded by direnv, which runs it with bash.
+var direnvDialect = &shellDialect{
+	file:    ".envrc",
+	usage:   "direnv allow",
+	quote:   shellQuote,
+	path:    "PATH_add %s",
+	setenv:  "export %s=%s",
+	opamEnv: `eval "$(%s env --switch=%s --set-switch --shell=bash)"`,
+}
+
+// activationMarker starts the activation scripts after the shebang. A
+// .envrc starting with it was written by rocq-bootstrap and is kept up to
+// date.
+const activationMarker = "# Activate the Rocq Platform "
+
+// script returns the activation script for env.
+func (d *shellDialect) script(env *ActivationEnv) string {
+	comment := d.comment
+	if comment == "" {
+		comment = "#"
+	}
+	what := "installation"
+	if env.SwitchName != "" {
+		what = "opam switch"
+	}
+	lines := []string{
+		fmt.Sprintf("%s Activate the Rocq Platform %s.", comment, what),
+		fmt.Sprintf("%s Usage: %s", comment, d.usage),
+	}
+	for _, dir := range env.pathDirs() {
+		lines = append(lines, fmt.Sprintf(d.path, d.quote(dir)))
+	}
+	if env.OpamRoot != "" {
+		lines = append(lines, fmt.Sprintf(d.setenv, "OPAMROOT", d.quote(env.OpamRoot)))
+	}
+	if env.SwitchName != "" {
+		opamCmd := "opam"
+		if env.OpamBin != "" {
+			opamCmd = d.quote(env.OpamBin)
+		}
+		lines = append(lines, fmt.Sprintf(d.opamEnv, opamCmd, d.quote(env.SwitchName)))
+	}
+	if d.after != "" {
+		lines = append(lines, d.after)
+	}
+	if d.echo != "" {
+		lines = append(lines, fmt.Sprintf(d.echo, d.quote(fmt.Sprintf("Rocq Platform activated (%s)", env.description()))))
+	}
+
+	// Batch files keep Windows line endings.
+	newline := "\n"
+	if strings.HasSuffix(d.shebang, "\r\n") {
+		newline = "\r\n"
+	}
+	return d.shebang + strings.Join(lines, newline) + newline
+}
+
+// WriteActivationScripts generates the activation scripts for the
+// environment on its target system: activate.sh (bash, zsh),
+// activate.fish, activate.nu and activate.ps1 on Linux, activate.sh and
+// activate.zsh on macOS, activate.ps1 and activate.bat on Windows. For an
+// opam switch, activate-shell.sh spawns an activated shell. A .envrc for
+// direnv is written when requested or when one was written before; a
+// .envrc not written by rocq-bootstrap is left alone.
+func WriteActivationScripts(workspaceDir string, env *ActivationEnv) error {
+	if env.SwitchName != "" {
+		log.Printf("[workspace] writing activation scripts for switch %s", env.SwitchName)
+	} else {
+		log.Printf("[workspace] writing activation scripts for %s", env.description())
+	}
+
+	scripts := env.dialects()
+	envrcPath := filepath.Join(workspaceDir, direnvDialect.file)
+	existing, err := os.ReadFile(envrcPath)
+	switch {
+	case err == nil && !strings.HasPrefix(string(existing), activationMarker):
+		if env.Direnv {
+			log.Printf("[workspace]   %s already exists, skipping", envrcPath)
+		}
+	case err == nil || env.Direnv:
+		scripts = append(scripts, direnvDialect)
+	}
+
+	for _, d := range scripts {
+		dest := filepath.Join(workspaceDir, d.file)
+		mode := os.FileMode(0o644)
+		if strings.HasPrefix(d.shebang, "#!") {
+			mode = 0o755
+		}
+		if err := os.WriteFile(dest, []byte(d.script(env)), mode); err != nil {
+			return fmt.Errorf("write %s: %w", d.file, err)
+		}
+		log.Printf("[workspace]   wrote %s", dest)
+	}
+	if env.SwitchName == "" {
+		return nil
+	}
+
+	// activate-shell.sh — spawns a new shell with the opam env
+	opamCmd := "opam"
+	envLines := ""
+	for _, dir := range env.pathDirs() {
+		envLines += fmt.Sprintf("export PATH=%s:\"$PATH\"\n", shellQuote(dir))
+	}
+	if env.OpamBin != "" {
+		opamCmd = shellQuote(env.OpamBin)
+	}
+	if env.OpamRoot != "" {
+		envLines += fmt.Sprintf("export OPAMROOT=%s\n", shellQuote(env.OpamRoot))
+	}
+	activateShellSh := fmt.Sprintf(`#!/usr/bin/env bash
+# Spawn a new shell with the Rocq Platform opam switch activated.
+# Usage: ./activate-shell.sh
+%secho "Launching shell with Rocq Platform (switch: %s)..."
+%s exec --switch=%s -- "${SHELL:-/bin/bash}"
+`, envLines, env.SwitchName, opamCmd, shellQuote(env.SwitchName))
+
+	activateShellPath := filepath.Join(workspaceDir, "activate-shell.sh")
+	if err := os.WriteFile(activateShellPath, []byte(activateShellSh), 0o755); err != nil {
+		return fmt.Errorf("write activate-shell.sh: %w", err)
+	}
+	log.Printf("[workspace]   wrote %s", activateShellPath)
+
+	return nil
+}
+
+// shellQuote quotes s for use as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s for use as a single fish word.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// nuQuote quotes s as a nushell string.
+func nuQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// pwshQuote quotes s as a PowerShell verbatim string.
+func pwshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// batQuote escapes s for a cmd.exe batch file, where % starts a variable.
+func batQuote(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
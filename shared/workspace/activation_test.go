@@ -0,0 +1,189 @@
+package workspace
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// activationTests are the environments the activation scripts are checked
+// for, each against the golden files in testdata/activation/<name>.
+var activationTests = []struct {
+	name string
+	env  ActivationEnv
+}{
+	{
+		name: "switch",
+		env: ActivationEnv{
+			SwitchName: "CP.2025.08.0~9.0",
+			Direnv:     true,
+			GOOS:       "linux",
+		},
+	},
+	{
+		name: "switch-opamroot",
+		env: ActivationEnv{
+			SwitchName: "CP.2025.08.0~9.0",
+			OpamRoot:   "/home/me/.rocq-setup/opam",
+			Direnv:     true,
+			GOOS:       "linux",
+		},
+	},
+	{
+		// The managed opam binary is not on PATH: its directory is added.
+		name: "managed-opam",
+		env: ActivationEnv{
+			SwitchName: "CP.2025.08.0~9.0",
+			OpamBin:    "/home/me/.rocq-setup/bin/opam",
+			OpamRoot:   "/home/me/it's mine/opam",
+			Direnv:     true,
+			GOOS:       "linux",
+		},
+	},
+	{
+		name: "darwin",
+		env: ActivationEnv{
+			Path: []string{"/Applications/Rocq-Platform~9.0~2025.08.app/Contents/Resources/bin"},
+			GOOS: "darwin",
+		},
+	},
+	{
+		// % starts a variable in batch files.
+		name: "windows",
+		env: ActivationEnv{
+			Path: []string{`C:\Rocq-platform~9.0~2025.08\bin`, `C:\Users\me\100%\bin`},
+			GOOS: "windows",
+		},
+	},
+}
+
+func TestWriteActivationScripts(t *testing.T) {
+	for _, tt := range activationTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			env := tt.env
+			if err := WriteActivationScripts(dir, &env); err != nil {
+				t.Fatal(err)
+			}
+			checkGoldenDir(t, dir, filepath.Join("testdata", "activation", tt.name))
+		})
+	}
+}
+
+// TestActivationBat checks that activate.bat has Windows line endings and
+// escapes % in paths.
+func TestActivationBat(t *testing.T) {
+	env := &ActivationEnv{Path: []string{`C:\Users\me\100%\bin`}, GOOS: "windows"}
+	script := batDialect.script(env)
+	if strings.Count(script, "\n") != strings.Count(script, "\r\n") {
+		t.Errorf("activate.bat has bare LF line endings:\n%q", script)
+	}
+	if want := `set "PATH=C:\Users\me\100%%\bin;%PATH%"` + "\r\n"; !strings.Contains(script, want) {
+		t.Errorf("activate.bat does not contain %q:\n%s", want, script)
+	}
+}
+
+// TestActivationNu checks that activate.nu drops the column titles of the
+// parsed variables, which load-env would otherwise get as name = "value".
+func TestActivationNu(t *testing.T) {
+	script := nuDialect.script(&activationTests[0].env)
+	if want := "| transpose --ignore-titles --header-row --as-record)"; !strings.Contains(script, want) {
+		t.Errorf("activate.nu does not contain %q:\n%s", want, script)
+	}
+}
+
+// TestActivationPwsh checks that activate.ps1 reads the sh output of
+// opam env, as --shell=pwsh needs opam 2.2 and the manifest allows 2.1.
+func TestActivationPwsh(t *testing.T) {
+	script := pwshDialect.script(&activationTests[0].env)
+	if strings.Contains(script, "--shell=pwsh") {
+		t.Errorf("activate.ps1 needs opam 2.2:\n%s", script)
+	}
+	if want := "--set-switch --shell=sh | ForEach-Object"; !strings.Contains(script, want) {
+		t.Errorf("activate.ps1 does not contain %q:\n%s", want, script)
+	}
+}
+
+// TestWriteActivationScriptsKeepsEnvrc checks that a .envrc not written by
+// rocq-bootstrap is left alone.
+func TestWriteActivationScriptsKeepsEnvrc(t *testing.T) {
+	dir := t.TempDir()
+	envrc := filepath.Join(dir, ".envrc")
+	const mine = "use nix\n"
+	if err := os.WriteFile(envrc, []byte(mine), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := activationTests[0].env
+	if err := WriteActivationScripts(dir, &env); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(envrc); string(got) != mine {
+		t.Errorf(".envrc = %q, want it unchanged", got)
+	}
+}
+
+// checkGoldenDir compares the files in dir with the <name>.golden files in
+// goldenDir, or rewrites them with -update.
+func checkGoldenDir(t *testing.T, dir, goldenDir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written []string
+	for _, e := range entries {
+		written = append(written, e.Name())
+	}
+
+	if *update {
+		if err := os.RemoveAll(goldenDir); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(goldenDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range written {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(goldenDir, name+".golden"), data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+
+	goldens, err := filepath.Glob(filepath.Join(goldenDir, "*.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, g := range goldens {
+		want = append(want, strings.TrimSuffix(filepath.Base(g), ".golden"))
+	}
+	slices.Sort(written)
+	slices.Sort(want)
+	if !slices.Equal(written, want) {
+		t.Errorf("wrote %v, want %v", written, want)
+	}
+	for _, name := range written {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden, err := os.ReadFile(filepath.Join(goldenDir, name+".golden"))
+		if err != nil {
+			t.Errorf("%s: %v (run go test -update)", name, err)
+			continue
+		}
+		if string(got) != string(golden) {
+			t.Errorf("%s differs from %s.golden:\n%s", name, name, got)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package workspace
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Build systems that WriteBuildFiles can set up.
+const (
+	BuildMake = "make" // Makefile driving the one generated by rocq makefile
+	BuildDune = "dune" // dune-project and dune files
+)
+
+// ProjectFile is the Rocq project file of a workspace.
+const ProjectFile = "_RocqProject"
+
+// Project is the part of _RocqProject the build files are derived from.
+type Project struct {
+	Dir    string   // first directory mapped with -Q or -R, slash-separated
+	Prefix string   // logical path of Dir; may be empty
+	Files  []string // listed .v files, slash-separated
+}
+
+// ReadProject parses the _RocqProject file of the workspace.
+func ReadProject(workspaceDir string) (*Project, error) {
+	data, err := os.ReadFile(filepath.Join(workspaceDir, ProjectFile))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", ProjectFile, err)
+	}
+
+	var words []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		words = append(words, strings.Fields(line)...)
+	}
+
+	p := &Project{}
+	for i := 0; i < len(words); i++ {
+		switch w := words[i]; {
+		case (w == "-Q" || w == "-R") && i+2 < len(words):
+			if p.Dir == "" {
+				p.Dir = path.Clean(filepath.ToSlash(words[i+1]))
+				p.Prefix = strings.Trim(words[i+2], `"`)
+			}
+			i += 2
+		case (w == "-I" || w == "-arg") && i+1 < len(words):
+			i++
+		case strings.HasSuffix(w, ".v"):
+			p.Files = append(p.Files, filepath.ToSlash(w))
+		}
+	}
+	if p.Dir == "" {
+		return nil, fmt.Errorf("%s maps no directory with -Q or -R", ProjectFile)
+	}
+	return p, nil
+}
+
+// WriteBuildFiles sets up the given build system for the project described
+// by _RocqProject:
+//   - BuildMake writes a Makefile generating RocqMakefile with
+//     `rocq makefile` (coq_makefile for Coq). When _RocqProject lists no
+//     files, the .v files of the mapped directory are passed to it.
+//   - BuildDune writes a dune-project and, in the mapped directory, a dune
+//     file declaring the theory (depending on Stdlib for Rocq 9+).
+//
+// A workspace that already has a Makefile, or a dune-project, is left
+// alone. Existing files are not overwritten.
+func WriteBuildFiles(workspaceDir, system string, coq bool) error {
+	log.Printf("[workspace] setting up %s build", system)
+
+	p, err := ReadProject(workspaceDir)
+	if err != nil {
+		return err
+	}
+
+	switch system {
+	case BuildMake:
+		return writeNew(filepath.Join(workspaceDir, "Makefile"), makefile(p, coq))
+	case BuildDune:
+		if _, err := os.Stat(filepath.Join(workspaceDir, "dune-project")); err == nil {
+			log.Printf("[workspace]   dune-project already exists, skipping")
+			return nil
+		}
+		if err := writeNew(filepath.Join(workspaceDir, filepath.FromSlash(p.Dir), "dune"), duneFile(p, workspaceDir, coq)); err != nil {
+			return err
+		}
+		return writeNew(filepath.Join(workspaceDir, "dune-project"), "(lang dune 3.8)\n(using coq 0.8)\n")
+	}
+	return fmt.Errorf("unknown build system %q", system)
+}
+
+// makefile returns a Makefile building the project through RocqMakefile.
+func makefile(p *Project, coq bool) string {
+	generator := "rocq makefile"
+	if coq {
+		generator = "coq_makefile"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Build the project with the Makefile generated from %s by `%s`.\n", ProjectFile, generator)
+	if len(p.Files) == 0 {
+		where := p.Dir + "/"
+		if p.Dir == "." {
+			where = "the workspace"
+		}
+		fmt.Fprintf(&b, "# Every .v file in %s is built: new files need no edit of %s.\n", where, ProjectFile)
+		fmt.Fprintf(&b, "VFILES := $(sort $(patsubst ./%%,%%,$(shell find %[1]s -name '*.v' -not -path '%[1]s/_opam/*' -not -path '%[1]s/_build/*' -not -path '*/.*')))\n", p.Dir)
+	}
+	b.WriteString("\nall: RocqMakefile\n\t$(MAKE) -f RocqMakefile\n\n")
+	fmt.Fprintf(&b, "RocqMakefile: %s Makefile $(VFILES)\n", ProjectFile)
+	fmt.Fprintf(&b, "\t%s -f %s -o RocqMakefile $(VFILES)\n\n", generator, ProjectFile)
+	b.WriteString("clean:\n\tif [ -f RocqMakefile ]; then $(MAKE) -f RocqMakefile cleanall; fi\n")
+	b.WriteString("\trm -f RocqMakefile RocqMakefile.conf\n\n.PHONY: all clean\n")
+	return b.String()
+}
+
+// duneFile returns the dune file declaring the project's theory, including
+// the subdirectories of the mapped directory as _RocqProject does.
+func duneFile(p *Project, workspaceDir string, coq bool) string {
+	name := p.Prefix
+	if name == "" {
+		name = LogicalName(filepath.Base(workspaceDir))
+	}
+
+	return duneTheory(name, coq)
+}
+
+// duneTheory returns a dune file declaring the theory with the given
+// logical name, depending on Stdlib for Rocq 9+. The dune template renders
+// it too, through TemplateVars.DuneTheory.
+func duneTheory(name string, coq bool) string {
+	var b strings.Builder
+	b.WriteString("(include_subdirs qualified)\n\n(coq.theory\n")
+	fmt.Fprintf(&b, " (name %s)", name)
+	if !coq {
+		b.WriteString("\n (theories Stdlib)")
+	}
+	b.WriteString(")\n")
+	return b.String()
+}
+
+// writeNew writes a generated file unless it already exists.
+func writeNew(dest, content string) error {
+	if _, err := os.Stat(dest); err == nil {
+		log.Printf("[workspace]   %s already exists, skipping", dest)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(dest), err)
+	}
+	if err := os.WriteFile(dest, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", dest, err)
+	}
+	log.Printf("[workspace]   wrote %s", dest)
+	return nil
+}
+
+// RunBuild runs the default build task of tasks in the workspace, after the
+// tasks it depends on, with env (as passed to WriteVSCodeTasks) added to
+// the environment.
+func RunBuild(workspaceDir string, tasks []Task, env map[string]string) error {
+	var chain []*Task
+	for i := range tasks {
+		if tasks[i].Build {
+			chain = append(chain, &tasks[i])
+			break
+		}
+	}
+	if len(chain) == 0 {
+		return fmt.Errorf("no build task")
+	}
+	for chain[0].DependsOn != "" && len(chain) <= len(tasks) {
+		dep := findTask(tasks, chain[0].DependsOn)
+		if dep == nil {
+			return fmt.Errorf("task %q depends on unknown task %q", chain[0].Label, chain[0].DependsOn)
+		}
+		chain = append([]*Task{dep}, chain...)
+	}
+
+	environ := os.Environ()
+	for k, v := range env {
+		environ = append(environ, k+"="+expandEnv(v))
+	}
+	for _, t := range chain {
+		log.Printf("[workspace] running %s: %s %s", t.Label, t.Command, strings.Join(t.Args, " "))
+		cmd := exec.Command(lookPath(t.Command, env["PATH"]), t.Args...)
+		cmd.Dir = workspaceDir
+		cmd.Env = environ
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s failed: %w\nOutput: %s", t.Label, err, output.String())
+		}
+	}
+	return nil
+}
+
+func findTask(tasks []Task, label string) *Task {
+	for i := range tasks {
+		if tasks[i].Label == label {
+			return &tasks[i]
+		}
+	}
+	return nil
+}
+
+// expandEnv replaces the ${env:NAME} references of a VSCode task
+// environment value with the variables of the current environment.
+func expandEnv(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${env:")
+		end := strings.IndexByte(s[max(start, 0):], '}')
+		if start < 0 || end < 0 {
+			break
+		}
+		b.WriteString(s[:start])
+		b.WriteString(os.Getenv(s[start+len("${env:") : start+end]))
+		s = s[start+end+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// lookPath resolves name in the directories of pathList (an expanded task
+// PATH) before the PATH of the current process, which exec.Command uses.
+func lookPath(name, pathList string) string {
+	for _, dir := range filepath.SplitList(expandEnv(pathList)) {
+		if dir == "" {
+			continue
+		}
+		if p, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+			return p
+		}
+	}
+	return name
+}
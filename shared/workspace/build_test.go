@@ -0,0 +1,68 @@
+package workspace
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestDuneTemplateMatchesBuildFiles checks that the dune template and
+// WriteBuildFiles declare the theory the same way.
+func TestDuneTemplateMatchesBuildFiles(t *testing.T) {
+	templates := fstest.MapFS{}
+	for _, f := range []string{"template.json", "_RocqProject.tmpl", "README.md.tmpl", "dune-project", "theories/dune.tmpl", "theories/Main.v"} {
+		data, err := os.ReadFile(filepath.Join("..", "..", "templates", "dune", filepath.FromSlash(f)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		templates[path.Join(TemplatesDir, "dune", f)] = &fstest.MapFile{Data: data}
+	}
+
+	for _, version := range []string{"9.0.0", "8.20.1"} {
+		t.Run(version, func(t *testing.T) {
+			fromTemplate := filepath.Join(t.TempDir(), "my-proofs")
+			if err := Create(fromTemplate, templates, "dune", TemplateVars{RocqVersion: version}); err != nil {
+				t.Fatal(err)
+			}
+			fromBuild := filepath.Join(t.TempDir(), "my-proofs")
+			if err := os.MkdirAll(fromBuild, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			project, err := os.ReadFile(filepath.Join(fromTemplate, ProjectFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(fromBuild, ProjectFile), project, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteBuildFiles(fromBuild, BuildDune, version == "8.20.1"); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, f := range []string{"dune-project", "theories/dune"} {
+				got, _ := os.ReadFile(filepath.Join(fromTemplate, filepath.FromSlash(f)))
+				want, _ := os.ReadFile(filepath.Join(fromBuild, filepath.FromSlash(f)))
+				if string(got) != string(want) {
+					t.Errorf("%s from the template = %q, want %q", f, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestMakefileSkipsBuildDirs checks that the generated Makefile skips the
+// opam and dune directories only, not every file starting with _.
+func TestMakefileSkipsBuildDirs(t *testing.T) {
+	m := makefile(&Project{Dir: "."}, false)
+	for _, want := range []string{"-not -path './_opam/*'", "-not -path './_build/*'"} {
+		if !strings.Contains(m, want) {
+			t.Errorf("Makefile does not contain %s:\n%s", want, m)
+		}
+	}
+	if strings.Contains(m, "'*/_*'") {
+		t.Errorf("Makefile skips every path with a _ component:\n%s", m)
+	}
+}
@@ -0,0 +1,155 @@
+package workspace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dev container configuration of the workspace: DevContainerFile and a
+// Dockerfile in DevContainerDir.
+const (
+	DevContainerDir  = ".devcontainer"
+	DevContainerFile = "devcontainer.json"
+)
+
+// devContainerMarker is in the first line of the files written by
+// WriteDevContainer. Files without it are left alone.
+const devContainerMarker = "Generated by rocq-bootstrap"
+
+// devContainerImage is the base image of the Dockerfile: Debian with opam,
+// as user opam with its root in devContainerOpamRoot.
+const (
+	devContainerImage    = "ocaml/opam:debian-12-opam"
+	devContainerOpamRoot = "/home/opam/.opam"
+)
+
+// DevContainer describes the opam switch a dev container recreates and
+// the VSCode extension it sets up.
+type DevContainer struct {
+	Name             string // shown by the editor
+	SwitchName       string // opam switch to create
+	Compiler         string // OCaml compiler package of the switch
+	RepoName         string // name and URL of the opam repository of the Rocq packages
+	RepoURL          string
+	Packages         []string // name=version, installed in the switch
+	Extension        string   // VSCode extension ID
+	ExtensionVersion string   // pinned extension version; empty means the latest
+	LanguageServer   string   // language server binary in the switch (vsrocqtop, vscoqtop)
+}
+
+// languageServerPath returns the path of the language server in the container.
+func (dc *DevContainer) languageServerPath() string {
+	return devContainerOpamRoot + "/" + dc.SwitchName + "/bin/" + dc.LanguageServer
+}
+
+// DevContainerDockerfile returns the Dockerfile creating the switch of dc.
+func DevContainerDockerfile(dc *DevContainer) string {
+	switchName := shellQuote(dc.SwitchName)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s: recreates the opam switch %s.\n", devContainerMarker, dc.SwitchName)
+	fmt.Fprintf(&b, "FROM %s\n\n", devContainerImage)
+	fmt.Fprintf(&b, "RUN opam switch create %s %s -y \\\n", switchName, shellQuote(dc.Compiler))
+	fmt.Fprintf(&b, " && opam repo add --switch=%s --rank=1 %s %s -y \\\n", switchName, shellQuote(dc.RepoName), shellQuote(dc.RepoURL))
+	fmt.Fprintf(&b, " && opam update --switch=%s \\\n", switchName)
+	fmt.Fprintf(&b, " && opam install --switch=%s --confirm-level=unsafe-yes \\\n", switchName)
+	for _, pkg := range dc.Packages {
+		fmt.Fprintf(&b, "      %s \\\n", shellQuote(pkg))
+	}
+	b.WriteString(" && opam clean --all-switches --download-cache --logs\n\n")
+	fmt.Fprintf(&b, "ENV OPAMSWITCH=\"%s\"\n", dockerEscape(dc.SwitchName))
+	fmt.Fprintf(&b, "ENV PATH=\"%s/%s/bin:$PATH\"\n", devContainerOpamRoot, dockerEscape(dc.SwitchName))
+	return b.String()
+}
+
+// dockerEscape escapes s for a double-quoted Dockerfile ENV value, where
+// backslashes escape and $ expands variables.
+func dockerEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(s)
+}
+
+// DevContainerJSON returns the DevContainerFile building the Dockerfile
+// and configuring the extension of dc with the language server of the
+// switch.
+func DevContainerJSON(dc *DevContainer) ([]byte, error) {
+	extension := dc.Extension
+	if dc.ExtensionVersion != "" {
+		extension += "@" + dc.ExtensionVersion
+	}
+	// vsrocqtop is configured by vsrocq.path, vscoqtop by vscoq.path.
+	settingsKey := strings.TrimSuffix(dc.LanguageServer, "top") + ".path"
+
+	type vscode struct {
+		Extensions []string          `json:"extensions"`
+		Settings   map[string]string `json:"settings"`
+	}
+	config := struct {
+		Name  string `json:"name"`
+		Build struct {
+			Dockerfile string `json:"dockerfile"`
+		} `json:"build"`
+		RemoteUser     string `json:"remoteUser"`
+		Customizations struct {
+			VSCode vscode `json:"vscode"`
+		} `json:"customizations"`
+	}{Name: dc.Name, RemoteUser: "opam"}
+	config.Build.Dockerfile = "Dockerfile"
+	config.Customizations.VSCode = vscode{
+		Extensions: []string{extension},
+		Settings:   map[string]string{settingsKey: dc.languageServerPath()},
+	}
+
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", DevContainerFile, err)
+	}
+	return append([]byte("// "+devContainerMarker+"\n"), append(content, '\n')...), nil
+}
+
+// WriteDevContainer writes the DevContainerFile and the Dockerfile of dc
+// into the DevContainerDir of the workspace, replacing the ones written
+// before but not files of the user.
+func WriteDevContainer(workspaceDir string, dc *DevContainer) error {
+	config, err := DevContainerJSON(dc)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(workspaceDir, DevContainerDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", DevContainerDir, err)
+	}
+	log.Printf("[workspace] writing dev container for %s", dc.SwitchName)
+
+	files := []struct{ name, content string }{
+		{DevContainerFile, string(config)},
+		{"Dockerfile", DevContainerDockerfile(dc)},
+	}
+	for _, f := range files {
+		dest := filepath.Join(dir, f.name)
+		if _, err := os.Stat(dest); err == nil && !isDevContainerFile(dest) {
+			log.Printf("[workspace]   %s already exists, skipping", dest)
+			continue
+		}
+		if err := os.WriteFile(dest, []byte(f.content), 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+		log.Printf("[workspace]   wrote %s", dest)
+	}
+	return nil
+}
+
+// isDevContainerFile reports whether the file at path was written by
+// WriteDevContainer.
+func isDevContainerFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	return s.Scan() && strings.Contains(s.Text(), devContainerMarker)
+}
@@ -0,0 +1,93 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// devContainerTests are the dev containers checked against the golden
+// files in testdata/devcontainer/<name>.
+var devContainerTests = []struct {
+	name string
+	dc   DevContainer
+}{
+	{
+		name: "rocq",
+		dc: DevContainer{
+			Name:       "Rocq Platform 2025.08.0 (Rocq 9.0.0)",
+			SwitchName: "CP.2025.08.0~9.0",
+			Compiler:   "ocaml-base-compiler.4.14.2",
+			RepoName:   "rocq-released",
+			RepoURL:    "https://rocq-prover.org/opam/released",
+			Packages: []string{
+				"rocq-runtime=9.0.0",
+				"rocq-core=9.0.0",
+				"rocq-stdlib=9.0.0",
+				"vsrocq-language-server=2.3.4",
+			},
+			Extension:        "rocq-prover.vsrocq",
+			ExtensionVersion: "2.3.4",
+			LanguageServer:   "vsrocqtop",
+		},
+	},
+	{
+		// No pinned extension version; the switch name needs quoting.
+		name: "coq",
+		dc: DevContainer{
+			Name:           "Rocq Platform 2024.10.1 (Coq 8.20.1)",
+			SwitchName:     "CP.2024.10.1~8.20 $HOME's",
+			Compiler:       "ocaml-base-compiler.4.14.2",
+			RepoName:       "coq-released",
+			RepoURL:        "https://coq.inria.fr/opam/released",
+			Packages:       []string{"coq=8.20.1", "vscoq-language-server=2.2.1"},
+			Extension:      "maximedenes.vscoq",
+			LanguageServer: "vscoqtop",
+		},
+	},
+}
+
+func TestWriteDevContainer(t *testing.T) {
+	for _, tt := range devContainerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := WriteDevContainer(dir, &tt.dc); err != nil {
+				t.Fatal(err)
+			}
+			checkGoldenDir(t, filepath.Join(dir, DevContainerDir), filepath.Join("testdata", "devcontainer", tt.name))
+		})
+	}
+}
+
+// TestWriteDevContainerKeepsUserFiles checks that files not written by
+// WriteDevContainer are left alone, and generated ones are replaced.
+func TestWriteDevContainerKeepsUserFiles(t *testing.T) {
+	dir := t.TempDir()
+	dc := &devContainerTests[0].dc
+	if err := WriteDevContainer(dir, dc); err != nil {
+		t.Fatal(err)
+	}
+	dockerfile := filepath.Join(dir, DevContainerDir, "Dockerfile")
+	const mine = "FROM debian\n"
+	if err := os.WriteFile(dockerfile, []byte(mine), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, DevContainerDir, DevContainerFile)
+	if err := os.WriteFile(config, []byte("// "+devContainerMarker+"\n{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteDevContainer(dir, dc); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dockerfile); string(got) != mine {
+		t.Errorf("Dockerfile = %q, want it unchanged", got)
+	}
+	want, err := DevContainerJSON(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(config); string(got) != string(want) {
+		t.Errorf("%s = %q, want it regenerated", DevContainerFile, got)
+	}
+}
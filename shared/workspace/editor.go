@@ -0,0 +1,22 @@
+package workspace
+
+import "fmt"
+
+// Editors the installer can configure for the workspace.
+const (
+	EditorVSCode = "vscode" // VSCode with the VSRocq (VSCoq) extension
+	EditorEmacs  = "emacs"  // Emacs with Proof General
+	EditorNeovim = "neovim" // Neovim with the language server, Vim with Coqtail
+)
+
+// SetupHint tells the user how to load the configuration written for the
+// given editor into the workspace, or returns "" when nothing is needed.
+func SetupHint(editor, workspaceDir string) string {
+	switch editor {
+	case EditorEmacs:
+		return fmt.Sprintf("Add to your Emacs init file:\n  %s", EmacsLoadForm(workspaceDir))
+	case EditorNeovim:
+		return fmt.Sprintf("Enable 'exrc' (set exrc) in Neovim or Vim to load %s or %s from the workspace", NeovimFile, VimFile)
+	}
+	return ""
+}
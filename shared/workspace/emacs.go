@@ -0,0 +1,133 @@
+package workspace
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// EmacsFile is the Emacs Lisp file configuring Proof General for the
+// workspace, loaded from the user's init file.
+const EmacsFile = "rocq-emacs.el"
+
+// emacsPathForm starts the form of EmacsFile putting the directories of
+// the Rocq binaries on exec-path; ReadEmacsConfig parses it back.
+const emacsPathForm = "(dolist (dir (reverse '("
+
+// EmacsLoadForm returns the form loading the EmacsFile of the workspace,
+// to be added to the user's Emacs init file.
+func EmacsLoadForm(workspaceDir string) string {
+	return fmt.Sprintf("(load %s)", elispQuote(filepath.Join(workspaceDir, EmacsFile)))
+}
+
+// WriteEmacsConfig writes the EmacsFile of the workspace. It puts the
+// directories of env.Path, which hold the Rocq binaries, first on Emacs'
+// exec-path and PATH, and points Proof General at the coqtop, coqc and
+// coqdep found there. The file does not go through opam: env.SwitchName
+// only names the switch in its header. With companyCoq, company-coq is
+// enabled in Rocq buffers when it is installed.
+func WriteEmacsConfig(workspaceDir string, env *ActivationEnv, companyCoq bool) error {
+	if len(env.Path) == 0 || slices.Contains(env.Path, "") {
+		return fmt.Errorf("no directory of Rocq binaries")
+	}
+	log.Printf("[workspace] writing %s for %s", EmacsFile, env.description())
+
+	dirs := make([]string, len(env.Path))
+	for i, dir := range env.Path {
+		dirs[i] = elispQuote(dir)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ";;; %s --- Rocq Platform setup for Proof General  -*- lexical-binding: t -*-\n\n", EmacsFile)
+	fmt.Fprintf(&b, ";; Generated by rocq-bootstrap for the Rocq Platform (%s).\n", env.description())
+	fmt.Fprintf(&b, ";; Load it from your Emacs init file:\n;;   %s\n\n", EmacsLoadForm(workspaceDir))
+	b.WriteString(emacsPathForm + strings.Join(dirs, " ") + ")))\n")
+	b.WriteString("  (setq exec-path (cons dir (delete dir exec-path)))\n")
+	b.WriteString("  (setenv \"PATH\" (mapconcat #'identity\n")
+	b.WriteString("                           (cons dir (delete dir (split-string (or (getenv \"PATH\") \"\") path-separator t)))\n")
+	b.WriteString("                           path-separator)))\n")
+	if env.OpamRoot != "" {
+		fmt.Fprintf(&b, "(setenv \"OPAMROOT\" %s)\n", elispQuote(env.OpamRoot))
+	}
+	b.WriteString("\n(setq coq-prog-name (or (executable-find \"coqtop\") \"coqtop\")\n")
+	b.WriteString("      coq-compiler (or (executable-find \"coqc\") \"coqc\")\n")
+	b.WriteString("      coq-dependency-analyzer (or (executable-find \"coqdep\") \"coqdep\"))\n\n")
+	b.WriteString("(unless (locate-library \"proof-site\")\n")
+	b.WriteString("  (display-warning 'rocq \"Proof General is not installed: M-x package-install RET proof-general\"))\n")
+	if companyCoq {
+		b.WriteString("\n(add-hook 'coq-mode-hook\n")
+		b.WriteString("          (lambda () (when (require 'company-coq nil t) (company-coq-mode 1))))\n")
+	}
+
+	dest := filepath.Join(workspaceDir, EmacsFile)
+	if err := os.WriteFile(dest, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", EmacsFile, err)
+	}
+	log.Printf("[workspace]   wrote %s", dest)
+	return nil
+}
+
+// EmacsConfig is what the EmacsFile of a workspace sets up.
+type EmacsConfig struct {
+	Path       []string // directories put first on exec-path
+	CompanyCoq bool     // company-coq enabled in Rocq buffers
+}
+
+// ReadEmacsConfig parses the EmacsFile of the workspace.
+func ReadEmacsConfig(workspaceDir string) (*EmacsConfig, error) {
+	data, err := os.ReadFile(filepath.Join(workspaceDir, EmacsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &EmacsConfig{CompanyCoq: strings.Contains(string(data), "(company-coq-mode 1)")}
+	for _, line := range strings.Split(string(data), "\n") {
+		rest, ok := strings.CutPrefix(line, emacsPathForm)
+		if !ok {
+			continue
+		}
+		for {
+			rest = strings.TrimLeft(rest, " ")
+			if !strings.HasPrefix(rest, `"`) {
+				if len(c.Path) == 0 {
+					break
+				}
+				return c, nil
+			}
+			var dir string
+			if dir, rest, ok = elispUnquote(rest); !ok {
+				break
+			}
+			c.Path = append(c.Path, dir)
+		}
+		break
+	}
+	return nil, fmt.Errorf("%s: no exec-path directories", EmacsFile)
+}
+
+// elispQuote quotes s as an Emacs Lisp string.
+func elispQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// elispUnquote reads the Emacs Lisp string starting s, returning its value
+// and what follows it.
+func elispUnquote(s string) (value, rest string, ok bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), s[i+1:], true
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", false
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return "", "", false
+}
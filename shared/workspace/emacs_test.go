@@ -0,0 +1,150 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// goldenWorkspace stands for the temporary workspace directory in the
+// golden files of the generated files naming it.
+const goldenWorkspace = "/home/me/rocq-workspace"
+
+// replaceInDir replaces old with new in the files of dir.
+func replaceInDir(t *testing.T, dir, old, new string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.ReplaceAll(string(data), old, new)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// emacsTests are the Emacs configurations checked against the golden
+// files in testdata/emacs/<name>.
+var emacsTests = []struct {
+	name       string
+	env        ActivationEnv
+	companyCoq bool
+}{
+	{
+		name: "switch",
+		env: ActivationEnv{
+			SwitchName: "CP.2025.08.0~9.0",
+			OpamRoot:   "/home/me/.rocq-setup/opam",
+			Path:       []string{"/home/me/.rocq-setup/opam/CP.2025.08.0~9.0/bin"},
+			GOOS:       "linux",
+		},
+		companyCoq: true,
+	},
+	{
+		// Backslashes and quotes are escaped.
+		name: "windows",
+		env: ActivationEnv{
+			Path: []string{`C:\Rocq-platform~9.0~2025.08\bin`, `C:\Users\me\"quoted"\bin`},
+			GOOS: "windows",
+		},
+	},
+}
+
+func TestWriteEmacsConfig(t *testing.T) {
+	for _, tt := range emacsTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := WriteEmacsConfig(dir, &tt.env, tt.companyCoq); err != nil {
+				t.Fatal(err)
+			}
+			replaceInDir(t, dir, dir, goldenWorkspace)
+			checkGoldenDir(t, dir, filepath.Join("testdata", "emacs", tt.name))
+		})
+	}
+}
+
+// TestEmacsConfigRoundTrip checks that ReadEmacsConfig returns what
+// WriteEmacsConfig was given.
+func TestEmacsConfigRoundTrip(t *testing.T) {
+	tests := [][]string{
+		{"/usr/bin"},
+		{"/opt/rocq platform/bin", "/home/me/.opam/default/bin"},
+		{`C:\Rocq-platform~9.0~2025.08\bin`},
+		{`/tmp/"quoted"/bin`, `/tmp/back\slash`, `/tmp/trailing\`, `/tmp/\"both\\`},
+		{"/tmp/paren (1)/bin", "/tmp/semi;colon"},
+	}
+	for _, path := range tests {
+		for _, companyCoq := range []bool{false, true} {
+			dir := t.TempDir()
+			if err := WriteEmacsConfig(dir, &ActivationEnv{Path: path}, companyCoq); err != nil {
+				t.Fatal(err)
+			}
+			c, err := ReadEmacsConfig(dir)
+			if err != nil {
+				t.Errorf("ReadEmacsConfig(%q): %v", path, err)
+				continue
+			}
+			if !slices.Equal(c.Path, path) {
+				t.Errorf("ReadEmacsConfig: Path = %q, want %q", c.Path, path)
+			}
+			if c.CompanyCoq != companyCoq {
+				t.Errorf("ReadEmacsConfig(%q): CompanyCoq = %v, want %v", path, c.CompanyCoq, companyCoq)
+			}
+		}
+	}
+}
+
+func TestReadEmacsConfigInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		";; my own setup\n(setq coq-prog-name \"coqtop\")\n",
+		emacsPathForm + "\"/usr/bin" + "\n",
+		emacsPathForm + ")))\n",
+	}
+	for _, content := range tests {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, EmacsFile), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if c, err := ReadEmacsConfig(dir); err == nil {
+			t.Errorf("ReadEmacsConfig(%q) = %+v, want an error", content, c)
+		}
+	}
+	if _, err := ReadEmacsConfig(t.TempDir()); !os.IsNotExist(err) {
+		t.Errorf("ReadEmacsConfig without %s: %v, want a not-exist error", EmacsFile, err)
+	}
+}
+
+func TestElispUnquote(t *testing.T) {
+	tests := []struct {
+		in          string
+		value, rest string
+		ok          bool
+	}{
+		{`"a" "b"`, "a", ` "b"`, true},
+		{`"" x`, "", " x", true},
+		{`"C:\\bin\\"`, `C:\bin\`, "", true},
+		{`"say \"hi\"")`, `say "hi"`, ")", true},
+		{`"unterminated`, "", "", false},
+		{`"escape at end\`, "", "", false},
+	}
+	for _, tt := range tests {
+		value, rest, ok := elispUnquote(tt.in)
+		if value != tt.value || rest != tt.rest || ok != tt.ok {
+			t.Errorf("elispUnquote(%q) = %q, %q, %v, want %q, %q, %v", tt.in, value, rest, ok, tt.value, tt.rest, tt.ok)
+		}
+		if tt.ok {
+			if q := elispQuote(tt.value); !strings.HasPrefix(tt.in, q) {
+				t.Errorf("elispQuote(%q) = %s, want the start of %s", tt.value, q, tt.in)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package workspace
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitIgnore lists the files kept out of git: compiled files, tactic
+// caches, the outputs of rocq makefile/coq_makefile and dune, a
+// project-local opam switch, and the files written for this machine
+// (activation scripts, .envrc and the Flatpak language server wrapper).
+var gitIgnore = []string{
+	"*.vo",
+	"*.vok",
+	"*.vos",
+	"*.glob",
+	"*.aux",
+	".lia.cache",
+	".nia.cache",
+	"RocqMakefile",
+	"RocqMakefile.conf",
+	".RocqMakefile.d",
+	"Makefile.coq",
+	"Makefile.coq.conf",
+	".Makefile.coq.d",
+	"_build/",
+	"_opam/",
+	".vscode/*.bak",
+	"activate.sh",
+	"activate.zsh",
+	"activate.fish",
+	"activate.nu",
+	"activate.ps1",
+	"activate.bat",
+	"activate-shell.sh",
+	".envrc",
+	".vscode/" + HostWrapperFile,
+}
+
+// lockFile is the lock file of the Linux installer (lockfile.FileName),
+// left for the user to commit: it records the language server path of
+// this machine too.
+const lockFile = "rocq-lock.json"
+
+// InitGit makes the workspace a git repository with a .gitignore for the
+// build artifacts and machine-specific files, and commits the files it
+// holds but the lock file. When the workspace is already inside a git work
+// tree, only the missing entries are added to its .gitignore and nothing
+// is committed.
+func InitGit(workspaceDir string) error {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return fmt.Errorf("git not found: %w", err)
+	}
+	run := func(args ...string) (string, error) {
+		cmd := exec.Command(git, append([]string{"-C", workspaceDir}, args...)...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("git %s failed: %w\nOutput: %s", args[0], err, stderr.String())
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	inside, _ := run("rev-parse", "--is-inside-work-tree")
+	if inside == "true" {
+		log.Printf("[workspace] %s is already in a git work tree", workspaceDir)
+		return writeGitIgnore(workspaceDir)
+	}
+
+	log.Printf("[workspace] initializing git repository in %s", workspaceDir)
+	if _, err := run("init", "--quiet"); err != nil {
+		return err
+	}
+	if err := writeGitIgnore(workspaceDir); err != nil {
+		return err
+	}
+	if _, err := run("add", "--all", "--", ".", ":(exclude)"+lockFile); err != nil {
+		return err
+	}
+
+	// Commit even where no identity is configured or commits are signed:
+	// the repository is the user's to amend.
+	commit := []string{"-c", "commit.gpgsign=false"}
+	if email, _ := run("config", "user.email"); email == "" {
+		commit = append(commit, "-c", "user.name=rocq-bootstrap", "-c", "user.email=rocq-bootstrap@localhost")
+	}
+	commit = append(commit, "commit", "--quiet", "-m", "Initial commit from rocq-bootstrap")
+	if _, err := run(commit...); err != nil {
+		return err
+	}
+	log.Printf("[workspace]   initial commit created")
+	return nil
+}
+
+// writeGitIgnore adds the entries of gitIgnore missing from the
+// workspace's .gitignore, creating it if needed.
+func writeGitIgnore(workspaceDir string) error {
+	path := filepath.Join(workspaceDir, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read .gitignore: %w", err)
+	}
+
+	present := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		present[strings.TrimSpace(line)] = true
+	}
+	var missing []string
+	for _, entry := range gitIgnore {
+		if !present[entry] {
+			missing = append(missing, entry)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var b bytes.Buffer
+	b.Write(data)
+	if len(data) > 0 {
+		if data[len(data)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("# Rocq build artifacts and machine-specific files (added by rocq-bootstrap)\n")
+	b.WriteString(strings.Join(missing, "\n") + "\n")
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("write .gitignore: %w", err)
+	}
+	log.Printf("[workspace]   added %d entries to %s", len(missing), path)
+	return nil
+}
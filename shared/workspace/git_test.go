@@ -0,0 +1,112 @@
+package workspace
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// gitTest skips the test without git, and keeps the user's git
+// configuration out of it.
+func gitTest(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+}
+
+// git runs git in dir and returns its output.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// writeFiles creates the named files in dir.
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestInitGit checks that a workspace left by an earlier run gets only
+// its template and build files committed.
+func TestInitGit(t *testing.T) {
+	gitTest(t)
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"main.v", "_RocqProject", "Makefile",
+		"main.vo", "main.glob", "_build/default/x",
+		"activate.sh", "activate.fish", "activate-shell.sh", ".envrc",
+		".vscode/"+HostWrapperFile, ".vscode/settings.json.bak",
+		lockFile,
+	)
+
+	if err := InitGit(dir); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(git(t, dir, "ls-files"), "\n")
+	want := []string{".gitignore", "Makefile", "_RocqProject", "main.v"}
+	if !slices.Equal(got, want) {
+		t.Errorf("committed %v, want %v", got, want)
+	}
+	if status := git(t, dir, "status", "--porcelain"); status != "?? "+lockFile {
+		t.Errorf("git status = %q, want only %s untracked", status, lockFile)
+	}
+}
+
+// TestInitGitInWorkTree checks that a workspace inside a git work tree
+// only gets the missing .gitignore entries, once, and no commit.
+func TestInitGitInWorkTree(t *testing.T) {
+	gitTest(t)
+	repo := t.TempDir()
+	git(t, repo, "init", "--quiet")
+	dir := filepath.Join(repo, "proofs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	const mine = "*.vo\nnotes/\n"
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(mine), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := InitGit(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), mine) {
+		t.Errorf(".gitignore lost its entries:\n%s", data)
+	}
+	for _, entry := range gitIgnore {
+		if n := strings.Count("\n"+string(data), "\n"+entry+"\n"); n != 1 {
+			t.Errorf(".gitignore has %s %d times, want once", entry, n)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		t.Error("a nested repository was created")
+	}
+	if err := exec.Command("git", "-C", repo, "rev-parse", "--verify", "--quiet", "HEAD").Run(); err == nil {
+		t.Error("a commit was made in the enclosing repository")
+	}
+}
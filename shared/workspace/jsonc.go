@@ -0,0 +1,491 @@
+package workspace
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// VSCode settings files are JSON with comments and trailing commas
+// (JSONC). The helpers below read them, and edit single members in place
+// so that everything else in the file, comments included, is kept as is.
+
+var errUnexpectedEnd = errors.New("unexpected end of input")
+
+// StripJSONC returns data with comments and trailing commas removed, so
+// that it can be decoded with encoding/json.
+func StripJSONC(data []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		switch c := data[i]; {
+		case c == '"':
+			end := skipString(data, i)
+			out.Write(data[i:end])
+			i = end
+		case isCommentStart(data, i):
+			i = skipComment(data, i)
+			out.WriteByte(' ')
+		case c == ',':
+			if j := skipSpace(data, i+1); j < len(data) && (data[j] == '}' || data[j] == ']') {
+				i++
+				continue
+			}
+			out.WriteByte(c)
+			i++
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+func isCommentStart(data []byte, i int) bool {
+	return data[i] == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*')
+}
+
+// skipComment returns the offset just past the comment starting at i.
+func skipComment(data []byte, i int) int {
+	if data[i+1] == '/' {
+		if end := bytes.IndexByte(data[i:], '\n'); end >= 0 {
+			return i + end
+		}
+		return len(data)
+	}
+	if end := bytes.Index(data[i+2:], []byte("*/")); end >= 0 {
+		return i + 2 + end + 2
+	}
+	return len(data)
+}
+
+// skipSpace returns the offset of the first byte at or after i that is
+// neither whitespace nor part of a comment.
+func skipSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch {
+		case data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r':
+			i++
+		case isCommentStart(data, i):
+			i = skipComment(data, i)
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipString returns the offset just past the string starting at i.
+func skipString(data []byte, i int) int {
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(data)
+}
+
+// skipValue returns the offset just past the value starting at i.
+func skipValue(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, errUnexpectedEnd
+	}
+	switch data[i] {
+	case '"':
+		return skipString(data, i), nil
+	case '{':
+		obj, err := scanObject(data, i)
+		if err != nil {
+			return 0, err
+		}
+		return obj.close + 1, nil
+	case '[':
+		j := skipSpace(data, i+1)
+		for {
+			if j >= len(data) {
+				return 0, errUnexpectedEnd
+			}
+			if data[j] == ']' {
+				return j + 1, nil
+			}
+			end, err := skipValue(data, j)
+			if err != nil {
+				return 0, err
+			}
+			j = skipSpace(data, end)
+			if j < len(data) && data[j] == ',' {
+				j = skipSpace(data, j+1)
+			}
+		}
+	}
+	j := i
+	for j < len(data) && bytes.IndexByte([]byte(" \t\r\n,}]/"), data[j]) < 0 {
+		j++
+	}
+	if j == i {
+		return 0, fmt.Errorf("offset %d: unexpected %q", i, data[i])
+	}
+	return j, nil
+}
+
+// jsonMember locates a member of a JSONC object.
+type jsonMember struct {
+	key        string
+	keyStart   int
+	valueStart int
+	valueEnd   int
+}
+
+// jsonObject locates a JSONC object and its members.
+type jsonObject struct {
+	open, close int
+	members     []jsonMember
+}
+
+// scanObject scans the object whose opening brace is at offset open.
+func scanObject(data []byte, open int) (*jsonObject, error) {
+	obj := &jsonObject{open: open}
+	i := skipSpace(data, open+1)
+	for {
+		if i >= len(data) {
+			return nil, errUnexpectedEnd
+		}
+		if data[i] == '}' {
+			obj.close = i
+			return obj, nil
+		}
+		if data[i] != '"' {
+			return nil, fmt.Errorf("offset %d: expected a key", i)
+		}
+		keyEnd := skipString(data, i)
+		var key string
+		if err := json.Unmarshal(data[i:keyEnd], &key); err != nil {
+			return nil, fmt.Errorf("offset %d: %w", i, err)
+		}
+		colon := skipSpace(data, keyEnd)
+		if colon >= len(data) || data[colon] != ':' {
+			return nil, fmt.Errorf("offset %d: expected ':'", colon)
+		}
+		valueStart := skipSpace(data, colon+1)
+		valueEnd, err := skipValue(data, valueStart)
+		if err != nil {
+			return nil, err
+		}
+		obj.members = append(obj.members, jsonMember{key: key, keyStart: i, valueStart: valueStart, valueEnd: valueEnd})
+
+		i = skipSpace(data, valueEnd)
+		if i < len(data) && data[i] == ',' {
+			i = skipSpace(data, i+1)
+		} else if i < len(data) && data[i] != '}' {
+			return nil, fmt.Errorf("offset %d: expected ',' or '}'", i)
+		}
+	}
+}
+
+// lineIndent returns the leading whitespace of the line holding offset i.
+func lineIndent(data []byte, i int) string {
+	start := bytes.LastIndexByte(data[:i], '\n') + 1
+	end := start
+	for end < i && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+// encodeJSONC encodes v as indented JSON whose continuation lines start
+// with indent, without escaping HTML characters.
+func encodeJSONC(v interface{}, indent string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(indent, "  ")
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(buf.Bytes(), "\n")), nil
+}
+
+// asObject returns v as a JSON object when it is a map.
+func asObject(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[string]string:
+		obj := make(map[string]interface{}, len(m))
+		for k, s := range m {
+			obj[k] = s
+		}
+		return obj, true
+	}
+	return nil, false
+}
+
+// withoutNil returns v with the nil members of its maps dropped, as they
+// mark members to remove rather than values to write, or nil when a map
+// is left with none.
+func withoutNil(v interface{}) interface{} {
+	obj, ok := asObject(v)
+	if !ok || len(obj) == 0 {
+		return v
+	}
+	kept := make(map[string]interface{}, len(obj))
+	for k, x := range obj {
+		if x = withoutNil(x); x != nil {
+			kept[k] = x
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func splice(data []byte, start, end int, text string) []byte {
+	out := make([]byte, 0, len(data)-(end-start)+len(text))
+	out = append(out, data[:start]...)
+	out = append(out, text...)
+	return append(out, data[end:]...)
+}
+
+// setMember returns data with the member key of the object opening at
+// offset open set to value, or removed when value is nil. A map value
+// is merged into an existing object member key by key; its nil members
+// are removed from it, and not written otherwise. When the key appears
+// more than once, the last member is set, as it is the one that applies,
+// and every one is removed.
+func setMember(data []byte, open int, key string, value interface{}) ([]byte, error) {
+	obj, err := scanObject(data, open)
+	if err != nil {
+		return nil, err
+	}
+	indent := lineIndent(data, open) + "  "
+	if len(obj.members) > 0 {
+		indent = lineIndent(data, obj.members[0].keyStart)
+	}
+
+	last := -1
+	for i, m := range obj.members {
+		if m.key == key {
+			last = i
+		}
+	}
+	if last >= 0 {
+		m := obj.members[last]
+		if value == nil {
+			return setMember(removeMember(data, obj, last), open, key, nil)
+		}
+		if sub, ok := asObject(value); ok && data[m.valueStart] == '{' {
+			// Edits inside the member leave its opening brace in place.
+			for _, k := range sortedKeys(sub) {
+				if data, err = setMember(data, m.valueStart, k, sub[k]); err != nil {
+					return nil, err
+				}
+			}
+			return data, nil
+		}
+		if value = withoutNil(value); value == nil {
+			return setMember(removeMember(data, obj, last), open, key, nil)
+		}
+		text, err := encodeJSONC(value, indent)
+		if err != nil {
+			return nil, err
+		}
+		return splice(data, m.valueStart, m.valueEnd, text), nil
+	}
+
+	if value = withoutNil(value); value == nil {
+		return data, nil
+	}
+	quoted, err := encodeJSONC(key, "")
+	if err != nil {
+		return nil, err
+	}
+	text, err := encodeJSONC(value, indent)
+	if err != nil {
+		return nil, err
+	}
+	entry := indent + quoted + ": " + text
+
+	if len(obj.members) > 0 {
+		last := obj.members[len(obj.members)-1]
+		after, comma := last.valueEnd, ""
+		j := after
+		for j < len(data) && (data[j] == ' ' || data[j] == '\t') {
+			j++
+		}
+		if j < len(data) && data[j] == ',' {
+			after, comma = j+1, ","
+		}
+		if end := lineEnd(data, after); !isBlank(data[after:end]) {
+			// Keep the trailing comment of the last member with it.
+			data = splice(data, end, end, "\n"+entry+comma)
+			if comma == "" {
+				data = splice(data, last.valueEnd, last.valueEnd, ",")
+			}
+			return data, nil
+		}
+		return splice(data, last.valueEnd, last.valueEnd, ",\n"+entry), nil
+	}
+	if len(bytes.TrimSpace(data[open+1:obj.close])) == 0 {
+		return splice(data, open+1, obj.close, "\n"+entry+"\n"+lineIndent(data, open)), nil
+	}
+	// The object only holds comments: add the member before them.
+	return splice(data, open+1, open+1, "\n"+entry), nil
+}
+
+// removeMember returns data without the i-th member of obj and its comma
+// (the comma ending the previous member for the last one). A member on a
+// line of its own goes with the line and its trailing // comment; the
+// comments of the other members are kept.
+func removeMember(data []byte, obj *jsonObject, i int) []byte {
+	m := obj.members[i]
+	start, end := m.keyStart, m.valueEnd
+	if j := skipSpace(data, end); j < len(data) && data[j] == ',' {
+		end = j + 1
+	} else if i > 0 {
+		comma := skipSpace(data, obj.members[i-1].valueEnd)
+		if isBlank(data[comma+1:start]) && isBlank(data[end:lineEnd(data, end)]) {
+			// No comment between the comma and the end of the member.
+			return splice(data, comma, end, "")
+		}
+		data = splice(data, comma, comma+1, "")
+		start, end = start-1, end-1
+	}
+
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+	if isBlank(data[lineStart:start]) {
+		if j := lineEnd(data, end); j == len(data) || data[j] == '\n' || data[j] == '\r' {
+			return splice(data, lineStart, skipNewline(data, j), "")
+		}
+	}
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return splice(data, start, end, "")
+}
+
+// lineEnd returns the offset past the spaces and // comment following
+// offset i on its line.
+func lineEnd(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+		i++
+	}
+	if i+1 < len(data) && data[i] == '/' && data[i+1] == '/' {
+		i = skipComment(data, i)
+	}
+	return i
+}
+
+// skipNewline returns the offset past the line break at offset i, if any.
+func skipNewline(data []byte, i int) int {
+	if i < len(data) && data[i] == '\r' {
+		i++
+	}
+	if i < len(data) && data[i] == '\n' {
+		i++
+	}
+	return i
+}
+
+func isBlank(data []byte) bool {
+	return len(bytes.TrimSpace(data)) == 0
+}
+
+// mergeJSONC sets the given top-level members of the JSONC object in
+// data (removing those whose value is nil) and leaves the rest untouched.
+func mergeJSONC(data []byte, values map[string]interface{}) ([]byte, error) {
+	if !json.Valid(StripJSONC(data)) {
+		return nil, errors.New("not valid JSON")
+	}
+	open := skipSpace(data, 0)
+	if open >= len(data) || data[open] != '{' {
+		return nil, errors.New("not a JSON object")
+	}
+	var err error
+	for _, k := range sortedKeys(values) {
+		if data, err = setMember(data, open, k, values[k]); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// readJSONCFile decodes the JSONC object in the file at path. An empty
+// file (or one holding only comments) yields an empty object.
+func readJSONCFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	if len(bytes.TrimSpace(StripJSONC(data))) == 0 {
+		return values, nil
+	}
+	if err := json.Unmarshal(StripJSONC(data), &values); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+	return values, nil
+}
+
+// updateJSONCFile merges into the JSONC object in the file at path the
+// top-level members returned by update, which is given the current
+// content (empty when the file does not exist). Other members and
+// comments are kept, and the previous file is saved with a .bak suffix.
+func updateJSONCFile(path string, update func(current map[string]interface{}) map[string]interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+
+	prev, err := os.ReadFile(path)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	data := prev
+	if len(bytes.TrimSpace(StripJSONC(prev))) == 0 {
+		// Missing, empty or only comments: start from an empty object.
+		data = append([]byte("{}\n"), prev...)
+	}
+
+	current := map[string]interface{}{}
+	if err := json.Unmarshal(StripJSONC(data), &current); err != nil {
+		return fmt.Errorf("%s: not valid JSON: %w", path, err)
+	}
+	values := update(current)
+	content, err := mergeJSONC(data, values)
+	if err != nil {
+		return fmt.Errorf("%s: %w; set %s manually", path, err, strings.Join(sortedKeys(values), ", "))
+	}
+	if exists && bytes.Equal(content, prev) {
+		log.Printf("[workspace]   %s already up to date", path)
+		return nil
+	}
+
+	if exists {
+		if err := os.WriteFile(path+".bak", prev, 0o644); err != nil {
+			return fmt.Errorf("back up %s: %w", filepath.Base(path), err)
+		}
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	log.Printf("[workspace]   wrote %s", path)
+	return nil
+}
@@ -0,0 +1,227 @@
+package workspace
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]interface{}
+	}{
+		{"comments", "{\n  // line\n  \"a\": 1, /* block */ \"b\": 2\n}", map[string]interface{}{"a": 1.0, "b": 2.0}},
+		{"trailing commas", `{"a": [1, 2,], "b": {"c": 3,},}`, map[string]interface{}{"a": []interface{}{1.0, 2.0}, "b": map[string]interface{}{"c": 3.0}}},
+		{"comment markers in strings", `{"u": "http://x/*y*/", "q": "a\"//b,}"}`, map[string]interface{}{"u": "http://x/*y*/", "q": `a"//b,}`}},
+		{"escapes", `{"p\\q": "C:\\bin\u00e9"}`, map[string]interface{}{`p\q`: "C:\\bin\u00e9"}},
+	}
+	for _, tt := range tests {
+		var got map[string]interface{}
+		if err := json.Unmarshal(StripJSONC([]byte(tt.in)), &got); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeJSONC(t *testing.T) {
+	const commented = "{\n  \"a\": 1, // about a\n  \"b\": 2, // about b\n  \"c\": 3 // about c\n}\n"
+	tests := []struct {
+		name   string
+		in     string
+		values map[string]interface{}
+		want   string
+	}{
+		{
+			name:   "add to empty object",
+			in:     "{}\n",
+			values: map[string]interface{}{"a": 1},
+			want:   "{\n  \"a\": 1\n}\n",
+		},
+		{
+			name:   "add after comments",
+			in:     "{\n  // editor\n  \"editor.fontSize\": 14\n}\n",
+			values: map[string]interface{}{"vsrocq.path": "/x"},
+			want:   "{\n  // editor\n  \"editor.fontSize\": 14,\n  \"vsrocq.path\": \"/x\"\n}\n",
+		},
+		{
+			name:   "add after trailing comment",
+			in:     "{\n  \"a\": 1 // about a\n}\n",
+			values: map[string]interface{}{"b": 2},
+			want:   "{\n  \"a\": 1, // about a\n  \"b\": 2\n}\n",
+		},
+		{
+			name:   "add after trailing comma and comment",
+			in:     "{\n  \"a\": 1, // about a\n}\n",
+			values: map[string]interface{}{"b": 2},
+			want:   "{\n  \"a\": 1, // about a\n  \"b\": 2,\n}\n",
+		},
+		{
+			name:   "add after trailing comma",
+			in:     "{\n  \"a\": 1,\n}\n",
+			values: map[string]interface{}{"b": 2},
+			want:   "{\n  \"a\": 1,\n  \"b\": 2,\n}\n",
+		},
+		{
+			name:   "replace keeping comments",
+			in:     "{\n  /* path */ \"a\": \"old\", // trailing\n  \"b\": 2\n}\n",
+			values: map[string]interface{}{"a": "new"},
+			want:   "{\n  /* path */ \"a\": \"new\", // trailing\n  \"b\": 2\n}\n",
+		},
+		{
+			name:   "escaped key and value",
+			in:     "{\n  \"a\\\"b\": \"x\\\\y\"\n}\n",
+			values: map[string]interface{}{`a"b`: `C:\bin`, "html": "a<b&c"},
+			want:   "{\n  \"a\\\"b\": \"C:\\\\bin\",\n  \"html\": \"a<b&c\"\n}\n",
+		},
+		{
+			name:   "remove first",
+			in:     commented,
+			values: map[string]interface{}{"a": nil},
+			want:   "{\n  \"b\": 2, // about b\n  \"c\": 3 // about c\n}\n",
+		},
+		{
+			name:   "remove middle",
+			in:     commented,
+			values: map[string]interface{}{"b": nil},
+			want:   "{\n  \"a\": 1, // about a\n  \"c\": 3 // about c\n}\n",
+		},
+		{
+			name:   "remove last",
+			in:     commented,
+			values: map[string]interface{}{"c": nil},
+			want:   "{\n  \"a\": 1, // about a\n  \"b\": 2 // about b\n}\n",
+		},
+		{
+			name:   "remove last without comments",
+			in:     "{\n  \"a\": 1,\n  \"b\": 2\n}\n",
+			values: map[string]interface{}{"b": nil},
+			want:   "{\n  \"a\": 1\n}\n",
+		},
+		{
+			name:   "remove only",
+			in:     "{\n  \"a\": 1\n}\n",
+			values: map[string]interface{}{"a": nil},
+			want:   "{\n}\n",
+		},
+		{
+			name:   "remove on one line",
+			in:     `{"a": 1, "b": 2, "c": 3}`,
+			values: map[string]interface{}{"a": nil, "c": nil},
+			want:   `{"b": 2}`,
+		},
+		{
+			name:   "remove missing",
+			in:     "{\n  \"a\": 1\n}\n",
+			values: map[string]interface{}{"b": nil},
+			want:   "{\n  \"a\": 1\n}\n",
+		},
+		{
+			name:   "set last duplicate",
+			in:     "{\n  \"a\": 1,\n  \"a\": 2\n}\n",
+			values: map[string]interface{}{"a": 3},
+			want:   "{\n  \"a\": 1,\n  \"a\": 3\n}\n",
+		},
+		{
+			name:   "remove duplicates",
+			in:     "{\n  \"a\": 1,\n  \"b\": 2,\n  \"a\": 3\n}\n",
+			values: map[string]interface{}{"a": nil},
+			want:   "{\n  \"b\": 2\n}\n",
+		},
+		{
+			name:   "merge into object",
+			in:     "{\n  \"env\": {\n    \"X\": \"1\", // keep\n    \"OPAMROOT\": \"/r\"\n  }\n}\n",
+			values: map[string]interface{}{"env": map[string]interface{}{"OPAMROOT": nil, "Y": "2"}},
+			want:   "{\n  \"env\": {\n    \"X\": \"1\", // keep\n    \"Y\": \"2\"\n  }\n}\n",
+		},
+		{
+			name:   "merge into last duplicate object",
+			in:     "{\n  \"env\": {\"X\": \"1\"},\n  \"env\": {\"X\": \"2\"}\n}\n",
+			values: map[string]interface{}{"env": map[string]string{"X": "3"}},
+			want:   "{\n  \"env\": {\"X\": \"1\"},\n  \"env\": {\"X\": \"3\"}\n}\n",
+		},
+		{
+			name:   "nil members not written",
+			in:     "{}\n",
+			values: map[string]interface{}{"env": map[string]interface{}{"OPAMROOT": nil}},
+			want:   "{}\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := mergeJSONC([]byte(tt.in), tt.values)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+		if !json.Valid(StripJSONC(got)) {
+			t.Errorf("%s: result is not valid JSONC: %q", tt.name, got)
+		}
+	}
+}
+
+func TestMergeJSONCInvalid(t *testing.T) {
+	for _, in := range []string{"", "[]", "{\"a\": }", "{\"a\": 1"} {
+		if got, err := mergeJSONC([]byte(in), map[string]interface{}{"a": 1}); err == nil {
+			t.Errorf("mergeJSONC(%q) = %q, want an error", in, got)
+		}
+	}
+}
+
+// TestUpdateJSONCFileEmpty checks that a missing, empty or comment-only
+// settings file is filled in, keeping the comments, and backed up.
+func TestUpdateJSONCFileEmpty(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *string
+		want    string
+	}{
+		{"missing", nil, "{\n  \"a\": 1\n}\n"},
+		{"empty", ptr(""), "{\n  \"a\": 1\n}\n"},
+		{"blank", ptr("\n\n"), "{\n  \"a\": 1\n}\n\n\n"},
+		{"comments only", ptr("// mine\n"), "{\n  \"a\": 1\n}\n// mine\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "settings.json")
+		if tt.content != nil {
+			if err := os.WriteFile(path, []byte(*tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := updateJSONCFile(path, func(current map[string]interface{}) map[string]interface{} {
+			if len(current) != 0 {
+				t.Errorf("%s: current = %v, want it empty", tt.name, current)
+			}
+			return map[string]interface{}{"a": 1}
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got, _ := os.ReadFile(path); string(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		_, err = os.Stat(path + ".bak")
+		if backedUp := err == nil; backedUp != (tt.content != nil) {
+			t.Errorf("%s: backed up = %v", tt.name, backedUp)
+		}
+
+		values, err := readJSONCFile(path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if values["a"] != 1.0 {
+			t.Errorf("%s: read %v", tt.name, values)
+		}
+	}
+}
+
+func ptr(s string) *string { return &s }
@@ -0,0 +1,44 @@
+package workspace
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// HostWrapperFile is the script in .vscode/ running the language server
+// on the host for an editor sandboxed by Flatpak, which cannot run the
+// binaries of the switch itself.
+const HostWrapperFile = "rocq-language-server.sh"
+
+// HostWrapperPath returns the path of the HostWrapperFile of the workspace.
+func HostWrapperPath(workspaceDir string) string {
+	return filepath.Join(workspaceDir, ".vscode", HostWrapperFile)
+}
+
+// HasHostWrapper reports whether the workspace has a HostWrapperFile.
+func HasHostWrapper(workspaceDir string) bool {
+	_, err := os.Stat(HostWrapperPath(workspaceDir))
+	return err == nil
+}
+
+// WriteHostWrapper writes the HostWrapperFile of the workspace, starting
+// topPath on the host through flatpak-spawn, and returns its path, to be
+// used as the language server path in the editor settings. The language
+// server stops with the editor.
+func WriteHostWrapper(workspaceDir, topPath string) (string, error) {
+	dest := HostWrapperPath(workspaceDir)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", fmt.Errorf("create .vscode: %w", err)
+	}
+	script := "#!/bin/sh\n" +
+		"# Generated by rocq-bootstrap: runs the language server on the host,\n" +
+		"# outside the Flatpak sandbox of the editor.\n" +
+		fmt.Sprintf("exec flatpak-spawn --host --watch-bus %s \"$@\"\n", shellQuote(topPath))
+	if err := os.WriteFile(dest, []byte(script), 0o755); err != nil {
+		return "", fmt.Errorf("write %s: %w", HostWrapperFile, err)
+	}
+	log.Printf("[workspace]   wrote %s", dest)
+	return dest, nil
+}
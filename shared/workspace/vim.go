@@ -0,0 +1,137 @@
+package workspace
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Local configuration files of the workspace, loaded from the directory
+// Neovim or Vim is started in when 'exrc' is set.
+const (
+	NeovimFile = ".nvim.lua" // language server, and Coqtail
+	VimFile    = ".vimrc"    // Coqtail
+)
+
+// vimMarker is in the first line of the files written by WriteVimConfig.
+// Files without it were not written by rocq-bootstrap and are left alone.
+const vimMarker = "Rocq Platform setup generated by rocq-bootstrap"
+
+// WriteVimConfig writes the NeovimFile and VimFile of the workspace. Both
+// put the directories of env.Path, which hold the Rocq binaries, first on
+// PATH and point Coqtail at the first one. The Neovim file also starts
+// the language server topPath for Rocq buffers, through vscoq.nvim when
+// it is installed; it is omitted from the file when empty.
+func WriteVimConfig(workspaceDir string, env *ActivationEnv, topPath string) error {
+	if len(env.Path) == 0 || slices.Contains(env.Path, "") {
+		return fmt.Errorf("no directory of Rocq binaries")
+	}
+	log.Printf("[workspace] writing Neovim/Vim configuration for %s", env.description())
+
+	files := []struct{ name, content string }{
+		{NeovimFile, neovimConfig(workspaceDir, env, topPath)},
+		{VimFile, vimConfig(env)},
+	}
+	for _, f := range files {
+		dest := filepath.Join(workspaceDir, f.name)
+		if _, err := os.Stat(dest); err == nil && !isVimConfig(dest) {
+			log.Printf("[workspace]   %s already exists, skipping", dest)
+			continue
+		}
+		if err := os.WriteFile(dest, []byte(f.content), 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+		log.Printf("[workspace]   wrote %s", dest)
+	}
+	return nil
+}
+
+// HasVimConfig reports whether the workspace has a NeovimFile or VimFile
+// written by WriteVimConfig.
+func HasVimConfig(workspaceDir string) bool {
+	return isVimConfig(filepath.Join(workspaceDir, NeovimFile)) || isVimConfig(filepath.Join(workspaceDir, VimFile))
+}
+
+// isVimConfig reports whether the file at path was written by WriteVimConfig.
+func isVimConfig(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	return s.Scan() && strings.Contains(s.Text(), vimMarker)
+}
+
+// neovimConfig returns the NeovimFile for env.
+func neovimConfig(workspaceDir string, env *ActivationEnv, topPath string) string {
+	dirs := make([]string, len(env.Path))
+	for i, dir := range env.Path {
+		dirs[i] = luaQuote(dir)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- %s (%s).\n", vimMarker, env.description())
+	b.WriteString("-- Neovim loads it from the workspace when 'exrc' is set (see :help exrc).\n\n")
+	fmt.Fprintf(&b, "local dirs = { %s }\n", strings.Join(dirs, ", "))
+	b.WriteString("for i = #dirs, 1, -1 do\n")
+	fmt.Fprintf(&b, "  vim.env.PATH = dirs[i] .. %s .. vim.env.PATH\n", luaQuote(env.listSeparator()))
+	b.WriteString("end\n")
+	if env.OpamRoot != "" {
+		fmt.Fprintf(&b, "vim.env.OPAMROOT = %s\n", luaQuote(env.OpamRoot))
+	}
+	b.WriteString("vim.g.coqtail_coq_path = dirs[1]\n")
+	if topPath != "" {
+		fmt.Fprintf(&b, "\nlocal cmd = { %s }\n", luaQuote(topPath))
+		b.WriteString("local ok, vscoq = pcall(require, \"vscoq\")\n")
+		b.WriteString("if ok then\n")
+		b.WriteString("  vscoq.setup({ lsp = { cmd = cmd } })\n")
+		b.WriteString("else\n")
+		b.WriteString("  vim.api.nvim_create_autocmd(\"FileType\", {\n")
+		b.WriteString("    pattern = \"coq\",\n")
+		b.WriteString("    callback = function()\n")
+		fmt.Fprintf(&b, "      vim.lsp.start({ name = %s, cmd = cmd, root_dir = %s })\n", luaQuote(strings.TrimSuffix(filepath.Base(topPath), ".exe")), luaQuote(workspaceDir))
+		b.WriteString("    end,\n")
+		b.WriteString("  })\n")
+		b.WriteString("end\n")
+	}
+	return b.String()
+}
+
+// vimConfig returns the VimFile for env.
+func vimConfig(env *ActivationEnv) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\" %s (%s).\n", vimMarker, env.description())
+	b.WriteString("\" Vim loads it from the workspace when 'exrc' is set (see :help exrc).\n\n")
+	for i := len(env.Path) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "let $PATH = %s . %s . $PATH\n", vimQuote(env.Path[i]), vimQuote(env.listSeparator()))
+	}
+	if env.OpamRoot != "" {
+		fmt.Fprintf(&b, "let $OPAMROOT = %s\n", vimQuote(env.OpamRoot))
+	}
+	fmt.Fprintf(&b, "let g:coqtail_coq_path = %s\n", vimQuote(env.Path[0]))
+	return b.String()
+}
+
+// listSeparator returns the PATH separator of the target system.
+func (e *ActivationEnv) listSeparator() string {
+	if e.GOOS == "windows" || (e.GOOS == "" && runtime.GOOS == "windows") {
+		return ";"
+	}
+	return ":"
+}
+
+// luaQuote quotes s as a Lua string.
+func luaQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// vimQuote quotes s as a Vim script literal string.
+func vimQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,81 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// vimTests are the Neovim/Vim configurations checked against the golden
+// files in testdata/vim/<name>.
+var vimTests = []struct {
+	name    string
+	env     ActivationEnv
+	topPath string
+}{
+	{
+		name: "switch",
+		env: ActivationEnv{
+			SwitchName: "CP.2025.08.0~9.0",
+			OpamRoot:   "/home/me/.rocq-setup/opam",
+			Path:       []string{"/home/me/.rocq-setup/opam/CP.2025.08.0~9.0/bin"},
+			GOOS:       "linux",
+		},
+		topPath: "/home/me/.rocq-setup/opam/CP.2025.08.0~9.0/bin/vsrocqtop",
+	},
+	{
+		// No language server; quotes are escaped in Lua and Vim script.
+		name: "windows",
+		env: ActivationEnv{
+			Path: []string{`C:\Rocq-platform~9.0~2025.08\bin`, `C:\Users\me's "quoted"\bin`},
+			GOOS: "windows",
+		},
+	},
+}
+
+func TestWriteVimConfig(t *testing.T) {
+	for _, tt := range vimTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := WriteVimConfig(dir, &tt.env, tt.topPath); err != nil {
+				t.Fatal(err)
+			}
+			if !HasVimConfig(dir) {
+				t.Error("HasVimConfig = false after WriteVimConfig")
+			}
+			replaceInDir(t, dir, dir, goldenWorkspace)
+			checkGoldenDir(t, dir, filepath.Join("testdata", "vim", tt.name))
+		})
+	}
+}
+
+// TestWriteVimConfigKeepsUserFiles checks that a .vimrc not written by
+// rocq-bootstrap is left alone, while a generated .nvim.lua is rewritten.
+func TestWriteVimConfigKeepsUserFiles(t *testing.T) {
+	dir := t.TempDir()
+	vimrc := filepath.Join(dir, VimFile)
+	const mine = "set number\n"
+	if err := os.WriteFile(vimrc, []byte(mine), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if HasVimConfig(dir) {
+		t.Error("HasVimConfig = true for the user's .vimrc")
+	}
+
+	env := vimTests[0].env
+	for _, topPath := range []string{"", vimTests[0].topPath} {
+		if err := WriteVimConfig(dir, &env, topPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, _ := os.ReadFile(vimrc); string(got) != mine {
+		t.Errorf("%s = %q, want it unchanged", VimFile, got)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, NeovimFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := neovimConfig(dir, &env, vimTests[0].topPath); string(got) != want {
+		t.Errorf("%s was not rewritten:\n%s", NeovimFile, got)
+	}
+}
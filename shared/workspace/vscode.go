@@ -0,0 +1,192 @@
+package workspace
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Task is a VSCode task running a process in the workspace.
+type Task struct {
+	Label     string
+	Command   string
+	Args      []string
+	Build     bool   // default task of the build group
+	DependsOn string // label of a task to run first
+}
+
+// BuildTasks returns the build and clean tasks for the build system found
+// in workspaceDir: dune when there is a dune-project, make when there is a
+// Makefile, and otherwise a RocqMakefile generated from _RocqProject with
+// `rocq makefile` (coq_makefile for Coq).
+func BuildTasks(workspaceDir string, coq bool) []Task {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(workspaceDir, name))
+		return err == nil
+	}
+
+	switch {
+	case exists("dune-project"):
+		return []Task{
+			{Label: "Rocq: build", Command: "dune", Args: []string{"build"}, Build: true},
+			{Label: "Rocq: clean", Command: "dune", Args: []string{"clean"}},
+		}
+	case exists("Makefile"):
+		return []Task{
+			{Label: "Rocq: build", Command: "make", Build: true},
+			{Label: "Rocq: clean", Command: "make", Args: []string{"clean"}},
+		}
+	}
+
+	generate := Task{Label: "Rocq: generate makefile", Command: "rocq", Args: []string{"makefile", "-f", "_RocqProject", "-o", "RocqMakefile"}}
+	if coq {
+		generate.Command = "coq_makefile"
+		generate.Args = generate.Args[1:]
+	}
+	return []Task{
+		generate,
+		{Label: "Rocq: build", Command: "make", Args: []string{"-f", "RocqMakefile"}, Build: true, DependsOn: generate.Label},
+		{Label: "Rocq: clean", Command: "make", Args: []string{"-f", "RocqMakefile", "clean"}, DependsOn: generate.Label},
+	}
+}
+
+// RunThrough returns tasks with each command run through command and its
+// leading args, e.g. "opam exec --switch=S --".
+func RunThrough(tasks []Task, command string, args ...string) []Task {
+	wrapped := make([]Task, len(tasks))
+	for i, t := range tasks {
+		t.Args = append(append(append([]string{}, args...), t.Command), t.Args...)
+		t.Command = command
+		wrapped[i] = t
+	}
+	return wrapped
+}
+
+// toJSON returns the task as it appears in tasks.json.
+func (t *Task) toJSON(env map[string]string) map[string]interface{} {
+	task := map[string]interface{}{
+		"label":          t.Label,
+		"type":           "process",
+		"command":        t.Command,
+		"args":           t.Args,
+		"problemMatcher": []interface{}{},
+	}
+	if t.Args == nil {
+		task["args"] = []string{}
+	}
+	if t.Build {
+		task["group"] = map[string]interface{}{"kind": "build", "isDefault": true}
+	}
+	if t.DependsOn != "" {
+		task["dependsOn"] = t.DependsOn
+	}
+	if len(env) > 0 {
+		task["options"] = map[string]interface{}{"env": env}
+	}
+	return task
+}
+
+// WriteVSCodeTasks merges tasks into .vscode/tasks.json: tasks with the
+// same label are replaced, other tasks are kept. env is added to the
+// environment of each task.
+func WriteVSCodeTasks(workspaceDir string, tasks []Task, env map[string]string) error {
+	log.Printf("[workspace] writing VSCode tasks")
+
+	return updateJSONCFile(filepath.Join(workspaceDir, ".vscode", "tasks.json"), func(current map[string]interface{}) map[string]interface{} {
+		ours := map[string]bool{}
+		for _, t := range tasks {
+			ours[t.Label] = true
+		}
+		var merged []interface{}
+		if existing, ok := current["tasks"].([]interface{}); ok {
+			for _, t := range existing {
+				if m, ok := t.(map[string]interface{}); ok && ours[labelOf(m)] {
+					continue
+				}
+				merged = append(merged, t)
+			}
+		}
+		for i := range tasks {
+			merged = append(merged, tasks[i].toJSON(env))
+		}
+		return map[string]interface{}{"version": "2.0.0", "tasks": merged}
+	})
+}
+
+func labelOf(task map[string]interface{}) string {
+	label, _ := task["label"].(string)
+	return label
+}
+
+// WriteVSCodeExtensions merges into .vscode/extensions.json a
+// recommendation for the extension recommended, and marks the extensions
+// in unwanted as not recommended.
+func WriteVSCodeExtensions(workspaceDir, recommended string, unwanted ...string) error {
+	log.Printf("[workspace] recommending VSCode extension %s", recommended)
+
+	return updateJSONCFile(filepath.Join(workspaceDir, ".vscode", "extensions.json"), func(current map[string]interface{}) map[string]interface{} {
+		skip := map[string]bool{recommended: true}
+		for _, id := range unwanted {
+			skip[id] = true
+		}
+		recommendations := mergeIDs(current["recommendations"], skip, recommended)
+
+		skip = map[string]bool{recommended: true}
+		return map[string]interface{}{
+			"recommendations":         recommendations,
+			"unwantedRecommendations": mergeIDs(current["unwantedRecommendations"], skip, unwanted...),
+		}
+	})
+}
+
+// mergeIDs returns the strings in list (a decoded JSON array) that are not
+// in skip, followed by add.
+func mergeIDs(list interface{}, skip map[string]bool, add ...string) []string {
+	ids := []string{}
+	if existing, ok := list.([]interface{}); ok {
+		for _, v := range existing {
+			if id, ok := v.(string); ok && !skip[id] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	for _, id := range add {
+		if !contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CodeWorkspaceFile returns the path of the .code-workspace file of the
+// workspace, named after its directory.
+func CodeWorkspaceFile(workspaceDir string) string {
+	return filepath.Join(workspaceDir, filepath.Base(workspaceDir)+".code-workspace")
+}
+
+// WriteCodeWorkspace merges into the workspace's .code-workspace file a
+// folder entry for the workspace directory, so that more folders can be
+// added to it in VSCode.
+func WriteCodeWorkspace(workspaceDir string) error {
+	path := CodeWorkspaceFile(workspaceDir)
+	log.Printf("[workspace] writing %s", path)
+
+	return updateJSONCFile(path, func(current map[string]interface{}) map[string]interface{} {
+		folders, _ := current["folders"].([]interface{})
+		for _, f := range folders {
+			if m, ok := f.(map[string]interface{}); ok && m["path"] == "." {
+				return nil
+			}
+		}
+		return map[string]interface{}{"folders": append([]interface{}{map[string]interface{}{"path": "."}}, folders...)}
+	})
+}
@@ -1,100 +1,313 @@
 package workspace
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/justme0606/rocq-bootstrap/shared/vscode"
 )
 
-// Create creates the workspace directory with template files.
-// Existing files are not overwritten.
-func Create(workspaceDir string, templates fs.FS) error {
-	log.Printf("[workspace] creating workspace at %s", workspaceDir)
+// DefaultName is the name of the default workspace directory, created in
+// the user's home directory.
+const DefaultName = "rocq-workspace"
 
-	if err := os.MkdirAll(filepath.Join(workspaceDir, ".vscode"), 0o755); err != nil {
-		return fmt.Errorf("create workspace dir: %w", err)
+// DefaultDir returns the default workspace directory (~/rocq-workspace).
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home dir: %w", err)
+	}
+	return filepath.Join(home, DefaultName), nil
+}
+
+// ResolveDir returns the absolute workspace directory for dir: DefaultDir
+// when dir is empty, with a leading ~ expanded to the home directory.
+func ResolveDir(dir string) (string, error) {
+	if dir == "" {
+		return DefaultDir()
+	}
+	if dir == "~" || strings.HasPrefix(dir, "~/") || strings.HasPrefix(dir, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("get home dir: %w", err)
+		}
+		dir = filepath.Join(home, dir[1:])
+	}
+	return filepath.Abs(dir)
+}
+
+// TemplatesDir is the directory of the embedded filesystem holding one
+// subdirectory per workspace template.
+const TemplatesDir = "embedded/templates"
+
+// DefaultTemplate is the template used when none is chosen.
+const DefaultTemplate = "minimal"
+
+// templateManifest is the file describing a template bundle.
+const templateManifest = "template.json"
+
+// renderSuffix marks template files rendered with text/template; the
+// suffix is dropped from the generated file name.
+const renderSuffix = ".tmpl"
+
+// Template is a named bundle of workspace files: a directory under
+// TemplatesDir whose template.json describes it and lists its files.
+type Template struct {
+	ID          string   `json:"-"` // directory name
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Files       []string `json:"files"` // slash-separated, relative to the template directory
+}
+
+// TemplateVars are the values available to rendered template files, e.g.
+// {{.LogicalPrefix}} in _RocqProject.tmpl.
+type TemplateVars struct {
+	ProjectName     string // workspace directory name
+	LogicalPrefix   string // Rocq logical path of the project (-Q . <prefix>)
+	TemplateName    string // display name of the template
+	RocqVersion     string
+	PlatformRelease string
+	SwitchName      string // opam switch (Linux)
+	InstallDir      string // Rocq Platform installation (macOS, Windows)
+}
+
+// DuneTheory returns the dune file declaring the project's theory, the
+// one WriteBuildFiles writes for BuildDune ({{.DuneTheory}}).
+func (v *TemplateVars) DuneTheory() string {
+	return duneTheory(v.LogicalPrefix, vscode.IsCoq(v.RocqVersion))
+}
+
+// LogicalName turns a project name into a valid Rocq logical path
+// component: "rocq-workspace" becomes "RocqWorkspace".
+func LogicalName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		default:
+			upper = true
+		}
+	}
+	logical := b.String()
+	if logical == "" {
+		return "Project"
+	}
+	if !unicode.IsLetter([]rune(logical)[0]) {
+		logical = "P" + logical
+	}
+	return logical
+}
+
+// ListTemplates returns the templates found in the embedded filesystem,
+// the default template first and the others in directory order.
+func ListTemplates(templates fs.FS) ([]*Template, error) {
+	entries, err := fs.ReadDir(templates, TemplatesDir)
+	if err != nil {
+		return nil, fmt.Errorf("read templates: %w", err)
 	}
 
-	files := []struct {
-		embeddedPath string
-		destName     string
-	}{
-		{"embedded/templates/test.v", "test.v"},
-		{"embedded/templates/main.v", "main.v"},
-		{"embedded/templates/_RocqProject", "_RocqProject"},
+	var list []*Template
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		t, err := LoadTemplate(templates, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if t.ID == DefaultTemplate {
+			list = append([]*Template{t}, list...)
+		} else {
+			list = append(list, t)
+		}
+	}
+	return list, nil
+}
+
+// LoadTemplate reads the description of the template with the given ID.
+func LoadTemplate(templates fs.FS, id string) (*Template, error) {
+	data, err := fs.ReadFile(templates, path.Join(TemplatesDir, id, templateManifest))
+	if err != nil {
+		return nil, fmt.Errorf("unknown template %q: %w", id, err)
 	}
 
-	for _, f := range files {
-		dest := filepath.Join(workspaceDir, f.destName)
+	var t Template
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parse template %q: %w", id, err)
+	}
+	t.ID = id
+	if t.Name == "" {
+		t.Name = id
+	}
+	for _, f := range t.Files {
+		if !fs.ValidPath(f) || f == "." {
+			return nil, fmt.Errorf("template %q: invalid file path %q", id, f)
+		}
+	}
+	return &t, nil
+}
+
+// Create creates the workspace directory with the files of the given
+// template (DefaultTemplate if empty). Files ending in .tmpl are rendered
+// with vars; ProjectName, LogicalPrefix and TemplateName default to values
+// derived from the workspace and template. Every file is rendered before
+// any is written. Existing files are not overwritten.
+func Create(workspaceDir string, templates fs.FS, templateID string, vars TemplateVars) error {
+	if templateID == "" {
+		templateID = DefaultTemplate
+	}
+	log.Printf("[workspace] creating workspace at %s (template %s)", workspaceDir, templateID)
+
+	t, err := LoadTemplate(templates, templateID)
+	if err != nil {
+		return err
+	}
+
+	if vars.ProjectName == "" {
+		vars.ProjectName = filepath.Base(workspaceDir)
+	}
+	if vars.LogicalPrefix == "" {
+		vars.LogicalPrefix = LogicalName(vars.ProjectName)
+	}
+	if vars.TemplateName == "" {
+		vars.TemplateName = t.Name
+	}
+
+	type output struct {
+		name string
+		dest string
+		data []byte
+	}
+	var outputs []output
+	for _, f := range t.Files {
+		name := strings.TrimSuffix(f, renderSuffix)
+		dest := filepath.Join(workspaceDir, filepath.FromSlash(name))
 		if _, err := os.Stat(dest); err == nil {
-			log.Printf("[workspace]   %s already exists, skipping", f.destName)
+			log.Printf("[workspace]   %s already exists, skipping", name)
 			continue // don't overwrite existing files
 		}
 
-		data, err := fs.ReadFile(templates, f.embeddedPath)
+		embeddedPath := path.Join(TemplatesDir, t.ID, f)
+		data, err := fs.ReadFile(templates, embeddedPath)
 		if err != nil {
-			log.Printf("[workspace]   ERROR reading template %s: %v", f.embeddedPath, err)
-			return fmt.Errorf("read template %s: %w", f.embeddedPath, err)
+			log.Printf("[workspace]   ERROR reading template %s: %v", embeddedPath, err)
+			return fmt.Errorf("read template %s: %w", embeddedPath, err)
 		}
+		if name != f {
+			if data, err = render(embeddedPath, data, &vars); err != nil {
+				log.Printf("[workspace]   ERROR rendering %v", err)
+				return err
+			}
+		}
+		outputs = append(outputs, output{name, dest, data})
+	}
 
-		if err := os.WriteFile(dest, data, 0o644); err != nil {
-			return fmt.Errorf("write %s: %w", dest, err)
+	if err := os.MkdirAll(filepath.Join(workspaceDir, ".vscode"), 0o755); err != nil {
+		return fmt.Errorf("create workspace dir: %w", err)
+	}
+	for _, o := range outputs {
+		if err := os.MkdirAll(filepath.Dir(o.dest), 0o755); err != nil {
+			return fmt.Errorf("create %s: %w", filepath.Dir(o.dest), err)
+		}
+		if err := os.WriteFile(o.dest, o.data, 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", o.dest, err)
 		}
-		log.Printf("[workspace]   wrote %s", dest)
+		log.Printf("[workspace]   wrote %s", o.dest)
 	}
 
 	log.Printf("[workspace] workspace created successfully")
 	return nil
 }
 
-// WriteVSCodeSettings writes .vscode/settings.json with the language server path.
-func WriteVSCodeSettings(workspaceDir, settingsKey, topPath string) error {
-	log.Printf("[workspace] writing VSCode settings with %s=%s", settingsKey, topPath)
-
-	content := fmt.Sprintf("{\n  \"%s\": \"%s\"\n}\n", settingsKey, topPath)
-
-	dest := filepath.Join(workspaceDir, ".vscode", "settings.json")
-	if err := os.WriteFile(dest, []byte(content), 0o644); err != nil {
-		return fmt.Errorf("write settings: %w", err)
+// render executes a template file. Errors from text/template name the
+// template file and line ("template: embedded/templates/x/README.md.tmpl:3:7: ...").
+func render(name string, data []byte, vars *TemplateVars) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("render %w", err)
 	}
-
-	log.Printf("[workspace]   wrote %s", dest)
-	return nil
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return nil, fmt.Errorf("render %w", err)
+	}
+	return buf.Bytes(), nil
 }
 
-// WriteActivationScripts generates shell activation scripts for the opam switch.
-func WriteActivationScripts(workspaceDir, switchName string) error {
-	log.Printf("[workspace] writing activation scripts for switch %s", switchName)
+// WriteVSCodeSettings merges the given settings (typically the language
+// server path) into .vscode/settings.json. Other settings and comments in
+// an existing file are kept, map values are merged into existing objects,
+// and a nil value removes the setting. The previous file is kept as
+// settings.json.bak.
+func WriteVSCodeSettings(workspaceDir string, settings map[string]interface{}) error {
+	log.Printf("[workspace] writing VSCode settings %v", settings)
 
-	// activate.sh — sources opam env for the switch
-	activateSh := fmt.Sprintf(`#!/usr/bin/env bash
-# Activate the Rocq Platform opam switch.
-# Usage: source activate.sh
-eval "$(opam env --switch=%s --set-switch)"
-echo "Rocq Platform activated (switch: %s)"
-`, switchName, switchName)
+	return updateJSONCFile(filepath.Join(workspaceDir, ".vscode", "settings.json"),
+		func(map[string]interface{}) map[string]interface{} { return settings })
+}
 
-	activatePath := filepath.Join(workspaceDir, "activate.sh")
-	if err := os.WriteFile(activatePath, []byte(activateSh), 0o755); err != nil {
-		return fmt.Errorf("write activate.sh: %w", err)
-	}
-	log.Printf("[workspace]   wrote %s", activatePath)
+// Scopes the language server setting can be written in.
+const (
+	ScopeWorkspace = "workspace" // .vscode/settings.json of the workspace
+	ScopeUser      = "user"      // user settings of the editor, for every folder
+)
 
-	// activate-shell.sh — spawns a new shell with the opam env
-	activateShellSh := fmt.Sprintf(`#!/usr/bin/env bash
-# Spawn a new shell with the Rocq Platform opam switch activated.
-# Usage: ./activate-shell.sh
-echo "Launching shell with Rocq Platform (switch: %s)..."
-opam exec --switch=%s -- "${SHELL:-/bin/bash}"
-`, switchName, switchName)
+// LanguageServerKeys are the settings naming the language server of
+// VSRocq and VSCoq.
+var LanguageServerKeys = []string{"vsrocq.path", "vscoq.path"}
 
-	activateShellPath := filepath.Join(workspaceDir, "activate-shell.sh")
-	if err := os.WriteFile(activateShellPath, []byte(activateShellSh), 0o755); err != nil {
-		return fmt.Errorf("write activate-shell.sh: %w", err)
+// WriteScopedSettings writes settings into .vscode/settings.json like
+// WriteVSCodeSettings, except that with a userSettings file (see
+// ScopeUser) the language server paths among them are merged into that
+// file instead, the same way and with the same backup, and removed from
+// the workspace settings, where they would override it.
+func WriteScopedSettings(workspaceDir, userSettings string, settings map[string]interface{}) error {
+	if userSettings != "" {
+		workspaceSettings := map[string]interface{}{}
+		userValues := map[string]interface{}{}
+		for k, v := range settings {
+			workspaceSettings[k] = v
+		}
+		for _, k := range LanguageServerKeys {
+			// Other folders may need the setting of the other extension:
+			// only set ones go to the user settings.
+			if v := settings[k]; v != nil {
+				userValues[k] = v
+				workspaceSettings[k] = nil
+			}
+		}
+		log.Printf("[workspace] writing user settings %v", userValues)
+		if err := updateJSONCFile(userSettings,
+			func(map[string]interface{}) map[string]interface{} { return userValues }); err != nil {
+			return err
+		}
+		settings = workspaceSettings
 	}
-	log.Printf("[workspace]   wrote %s", activateShellPath)
+	return WriteVSCodeSettings(workspaceDir, settings)
+}
 
-	return nil
+// ReadUserSettings returns the settings in the user settings file at path
+// (see ScopeUser), which may contain comments and trailing commas.
+func ReadUserSettings(path string) (map[string]interface{}, error) {
+	return readJSONCFile(path)
+}
+
+// ReadVSCodeSettings returns the settings in .vscode/settings.json, which
+// may contain comments and trailing commas.
+func ReadVSCodeSettings(workspaceDir string) (map[string]interface{}, error) {
+	return readJSONCFile(filepath.Join(workspaceDir, ".vscode", "settings.json"))
 }
@@ -0,0 +1,6 @@
+(** Basic definitions of the library. *)
+
+Definition double (n : nat) : nat := n + n.
+
+Lemma double_zero : double 0 = 0.
+Proof. reflexivity. Qed.
@@ -13,7 +13,8 @@ cd "$SCRIPT_DIR"
 
 echo "==> Syncing embedded assets..."
 cp -f ../manifest/latest.json embedded/manifest/latest.json
-cp -f ../templates/test.v embedded/templates/test.v
+rm -rf embedded/templates
+cp -R ../templates embedded/templates
 
 echo "==> Building rocq-bootstrap.exe (Windows amd64)..."
 CGO_ENABLED=1 \
@@ -1,7 +1,6 @@
 package doctor
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,7 +9,11 @@ import (
 
 	"golang.org/x/sys/windows/registry"
 
+	wsregistry "github.com/justme0606/rocq-bootstrap/shared/registry"
+	"github.com/justme0606/rocq-bootstrap/shared/settings"
+	"github.com/justme0606/rocq-bootstrap/windows/internal/emacs"
 	"github.com/justme0606/rocq-bootstrap/windows/internal/vscode"
+	"github.com/justme0606/rocq-bootstrap/windows/internal/workspace"
 )
 
 // Run performs system diagnostics and reports findings via onLog callback.
@@ -24,15 +27,24 @@ func Run(onLog func(string)) {
 
 	onLog("")
 	onLog("=== VSCode ===")
-	vsrocqFound, vscoqFound := checkVSCode(onLog)
+	extension := workspaceExtension()
+	found, conflictFound := checkVSCode(extension, onLog)
+
+	onLog("")
+	onLog("=== Emacs ===")
+	checkEmacs(onLog)
 
 	onLog("")
 	onLog("=== Workspace ===")
 	checkWorkspaceWindows(onLog)
 
+	onLog("")
+	onLog("=== Registered Workspaces ===")
+	checkRegisteredWorkspaces(onLog)
+
 	onLog("")
 	onLog("=== Potential Issues ===")
-	checkIssues(onLog, installFound, vsrocqFound, vscoqFound)
+	checkIssues(onLog, installFound, extension, found, conflictFound)
 }
 
 // installation holds info about a found Rocq installation.
@@ -224,15 +236,114 @@ func checkBinariesWindows(onLog func(string)) {
 	}
 }
 
-func checkVSCode(onLog func(string)) (vsrocqFound, vscoqFound bool) {
-	codeBin, err := vscode.FindCode()
-	if err != nil {
+// checkVSCode reports the VSCode-family editors and their Rocq/Coq
+// extensions, and whether extension and the one conflicting with it are
+// installed in any of them.
+func checkVSCode(extension string, onLog func(string)) (found, conflictFound bool) {
+	editors := vscode.FindEditors()
+	if len(editors) == 0 {
 		onLog("  VSCode not found")
 		return false, false
 	}
-	onLog(fmt.Sprintf("  CLI: %s", codeBin))
+	serverExtension, serverVersion := workspaceLanguageServer()
+	if serverVersion != "" {
+		onLog(fmt.Sprintf("  Workspace language server: %s", serverVersion))
+	}
+	profile := workspaceProfile()
+	if profile != "" {
+		onLog(fmt.Sprintf("  Workspace VSCode profile: %s", profile))
+	}
+	for _, e := range editors {
+		onLog(fmt.Sprintf("  %s CLI: %s", e.Label(), e.Bin))
+		f, c := checkExtensions(e.Bin, profile, extension, serverExtension, serverVersion, onLog)
+		found = found || f
+		conflictFound = conflictFound || c
+	}
+	return found, conflictFound
+}
+
+// workspaceProfile returns the VSCode profile the workspace was set up
+// with, or "" for the default profile.
+func workspaceProfile() string {
+	wsDir, err := settings.Load().Workspace()
+	if err != nil {
+		return ""
+	}
+	reg, err := wsregistry.Load()
+	if err != nil {
+		return ""
+	}
+	if e := reg.Find(wsDir); e != nil {
+		return e.Profile
+	}
+	return ""
+}
+
+// workspaceExtension returns the extension the current workspace needs:
+// the one its settings configure, or else the one of the release it is
+// registered with, VSRocq if unknown.
+func workspaceExtension() string {
+	if extensionID, _ := workspaceLanguageServer(); extensionID != "" {
+		return extensionID
+	}
+	if wsDir, err := settings.Load().Workspace(); err == nil {
+		if reg, err := wsregistry.Load(); err == nil {
+			if e := reg.Find(wsDir); e != nil && e.RocqVersion != "" {
+				return vscode.ExtensionIDForVersion(e.RocqVersion)
+			}
+		}
+	}
+	return vscode.RocqExtensionID
+}
 
-	out, err := exec.Command(codeBin, "--list-extensions", "--show-versions").Output()
+// workspaceLanguageServer returns the extension the VSCode settings of the
+// workspace configure and the version of the language server they point
+// it at, or empty strings. Without a path in the workspace settings, those
+// of the user settings file the workspace was set up with are returned.
+func workspaceLanguageServer() (extensionID, version string) {
+	wsDir, err := settings.Load().Workspace()
+	if err != nil {
+		return "", ""
+	}
+	wsSettings, _ := workspace.ReadVSCodeSettings(wsDir)
+	if extensionID, version = languageServer(wsSettings); extensionID != "" {
+		return extensionID, version
+	}
+	reg, err := wsregistry.Load()
+	if err != nil {
+		return "", ""
+	}
+	if e := reg.Find(wsDir); e != nil && e.UserSettings != "" {
+		userSettings, _ := workspace.ReadUserSettings(e.UserSettings)
+		return languageServer(userSettings)
+	}
+	return "", ""
+}
+
+// languageServer returns the extension vsSettings configure and the
+// version of the language server they point it at, or empty strings.
+func languageServer(vsSettings map[string]interface{}) (extensionID, version string) {
+	if p, ok := vsSettings["vsrocq.path"].(string); ok && p != "" {
+		return vscode.RocqExtensionID, vscode.LanguageServerVersion(p)
+	}
+	if p, ok := vsSettings["vscoq.path"].(string); ok && p != "" {
+		return vscode.CoqExtensionID, vscode.LanguageServerVersion(p)
+	}
+	return "", ""
+}
+
+// checkExtensions reports the Rocq/Coq extensions of the VSCode-family
+// editor with the given CLI in the profile ("" for the default one),
+// whether extension and the one conflicting with it are installed, and
+// whether the one of serverExtension works with the language server of
+// the given version (if known).
+func checkExtensions(codeBin, profile, extension, serverExtension, serverVersion string, onLog func(string)) (found, conflictFound bool) {
+	conflict := vscode.ConflictingExtensionID(extension)
+	args := []string{"--list-extensions", "--show-versions"}
+	if profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	out, err := exec.Command(codeBin, args...).Output()
 	if err != nil {
 		onLog("  (could not list extensions)")
 		return false, false
@@ -247,57 +358,141 @@ func checkVSCode(onLog func(string)) (vsrocqFound, vscoqFound bool) {
 		if strings.Contains(lower, "rocq") || strings.Contains(lower, "coq") {
 			onLog(fmt.Sprintf("    %s", line))
 			anyExt = true
-			if strings.Contains(lower, "vsrocq") {
-				vsrocqFound = true
-			}
-			if strings.Contains(lower, "vscoq") {
-				vscoqFound = true
+			id, version, ok := strings.Cut(line, "@")
+			found = found || strings.EqualFold(id, extension)
+			conflictFound = conflictFound || strings.EqualFold(id, conflict)
+			if ok && serverVersion != "" && strings.EqualFold(id, serverExtension) && !vscode.CompatibleVersions(version, serverVersion) {
+				onLog(fmt.Sprintf("    \u26a0 %s %s is not compatible with language server %s", id, version, serverVersion))
 			}
 		}
 	}
 	if !anyExt {
 		onLog("    (no Rocq/Coq extensions)")
 	}
-	if !vsrocqFound {
-		onLog("  \u26a0 vsrocq extension not found")
+	if !found {
+		onLog(fmt.Sprintf("  \u26a0 %s extension not found", extension))
 	}
-	if vscoqFound {
-		onLog("  \u26a0 vscoq extension detected (deprecated, use vsrocq instead)")
+	if conflictFound {
+		onLog(fmt.Sprintf("  \u26a0 %s extension detected, which conflicts with %s", conflict, extension))
 	}
 
-	return vsrocqFound, vscoqFound
+	return found, conflictFound
 }
 
 func checkWorkspaceWindows(onLog func(string)) {
-	home, err := os.UserHomeDir()
+	wsDir, err := settings.Load().Workspace()
 	if err != nil {
-		onLog("  (could not determine home directory)")
+		onLog(fmt.Sprintf("  (could not determine workspace directory: %v)", err))
 		return
 	}
 
-	wsDir := filepath.Join(home, "rocq-workspace")
 	if info, err := os.Stat(wsDir); err == nil && info.IsDir() {
 		onLog(fmt.Sprintf("  \u2713 %s", wsDir))
 
-		settingsPath := filepath.Join(wsDir, ".vscode", "settings.json")
-		if data, err := os.ReadFile(settingsPath); err == nil {
-			var settings map[string]interface{}
-			if err := json.Unmarshal(data, &settings); err == nil {
-				if v, ok := settings["vsrocq.path"]; ok {
-					onLog(fmt.Sprintf("  settings.json: vsrocq.path = %v", v))
-				} else {
-					onLog("  settings.json: vsrocq.path not set")
+		wsPathSet := false
+		if settings, err := workspace.ReadVSCodeSettings(wsDir); err == nil {
+			for _, key := range workspace.LanguageServerKeys {
+				if v, ok := settings[key]; ok && v != nil {
+					onLog(fmt.Sprintf("  settings.json: %s = %v", key, v))
+					wsPathSet = true
 				}
 			}
-		} else {
+			if !wsPathSet {
+				onLog("  settings.json: no language server path, the user settings apply")
+			}
+		} else if os.IsNotExist(err) {
 			onLog("  .vscode/settings.json not found")
+		} else {
+			onLog(fmt.Sprintf("  \u26a0 .vscode/settings.json: %v", err))
+		}
+		if !checkUserSettings(wsDir, onLog) && !wsPathSet {
+			onLog("  \u26a0 No language server path in the workspace or user settings")
+		}
+		checkEmacsConfig(wsDir, onLog)
+		for _, f := range []string{workspace.NeovimFile, workspace.VimFile} {
+			if _, err := os.Stat(filepath.Join(wsDir, f)); err == nil {
+				onLog(fmt.Sprintf("  \u2713 %s present", f))
+			}
 		}
 	} else {
 		onLog(fmt.Sprintf("  %s not found", wsDir))
 	}
 }
 
-func checkIssues(onLog func(string), installFound, vsrocqFound, vscoqFound bool) {
+func checkEmacs(onLog func(string)) {
+	emacsBin, err := emacs.FindEmacs()
+	if err != nil {
+		onLog("  Emacs not found")
+		return
+	}
+	onLog(fmt.Sprintf("  Emacs: %s", emacsBin))
+
+	if emacs.HasPackage(emacsBin, emacs.ProofGeneral) {
+		onLog("  \u2713 Proof General installed")
+	} else {
+		onLog("  \u26a0 Proof General not found")
+	}
+	if emacs.HasPackage(emacsBin, emacs.CompanyCoq) {
+		onLog("  \u2713 company-coq installed")
+	}
+}
+
+// checkUserSettings reports the language server paths in the user settings
+// of the VSCode-family editors, for the profile the workspace opens in,
+// warns when the workspace settings, which override them, set a different
+// one, and returns whether any user settings set one.
+func checkUserSettings(wsDir string, onLog func(string)) (found bool) {
+	wsSettings, _ := workspace.ReadVSCodeSettings(wsDir)
+	profile := workspaceProfile()
+	for _, e := range vscode.FindEditors() {
+		path, err := e.UserSettingsPath(profile)
+		if err != nil {
+			continue
+		}
+		userSettings, err := workspace.ReadUserSettings(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			onLog(fmt.Sprintf("  \u26a0 %s user settings: %v", e.Label(), err))
+			continue
+		}
+		for _, key := range workspace.LanguageServerKeys {
+			userValue, ok := userSettings[key]
+			if !ok || userValue == nil {
+				continue
+			}
+			found = true
+			onLog(fmt.Sprintf("  %s user settings: %s = %v", e.Label(), key, userValue))
+			if wsValue, ok := wsSettings[key]; ok && wsValue != nil && fmt.Sprint(wsValue) != fmt.Sprint(userValue) {
+				onLog(fmt.Sprintf("  \u26a0 %s differs between the workspace (%v) and the %s user settings (%v); the workspace one applies", key, wsValue, e.Label(), userValue))
+			}
+		}
+	}
+	return found
+}
+
+// checkEmacsConfig reports the binaries the workspace's Emacs file points
+// Proof General at, if the workspace has one.
+func checkEmacsConfig(wsDir string, onLog func(string)) {
+	ec, err := workspace.ReadEmacsConfig(wsDir)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		onLog(fmt.Sprintf("  \u26a0 %s: %v", workspace.EmacsFile, err))
+		return
+	}
+	for _, dir := range ec.Path {
+		if _, err := os.Stat(filepath.Join(dir, "coqtop.exe")); err == nil {
+			onLog(fmt.Sprintf("  %s: exec-path %s", workspace.EmacsFile, dir))
+		} else {
+			onLog(fmt.Sprintf("  \u26a0 %s: no coqtop in %s", workspace.EmacsFile, dir))
+		}
+	}
+}
+
+func checkIssues(onLog func(string), installFound bool, extension string, found, conflictFound bool) {
 	anyIssue := false
 
 	if !installFound {
@@ -321,13 +516,17 @@ func checkIssues(onLog func(string), installFound, vsrocqFound, vscoqFound bool)
 		anyIssue = true
 	}
 
-	if !vsrocqFound {
-		onLog("  \u26a0 vsrocq extension not installed \u2014 required for Rocq support in VSCode")
+	language := "Rocq"
+	if extension == vscode.CoqExtensionID {
+		language = "Coq"
+	}
+	if !found {
+		onLog(fmt.Sprintf("  \u26a0 %s extension not installed \u2014 required for %s support in VSCode", extension, language))
 		anyIssue = true
 	}
 
-	if vscoqFound {
-		onLog("  \u26a0 vscoq extension is installed \u2014 deprecated, may conflict with vsrocq")
+	if conflictFound {
+		onLog(fmt.Sprintf("  \u26a0 %s extension is installed \u2014 conflicts with %s (disable it for the workspace, or rerun with --uninstall-conflicting)", vscode.ConflictingExtensionID(extension), extension))
 		anyIssue = true
 	}
 
@@ -335,3 +534,26 @@ func checkIssues(onLog func(string), installFound, vsrocqFound, vscoqFound bool)
 		onLog("  (no issues detected)")
 	}
 }
+
+func checkRegisteredWorkspaces(onLog func(string)) {
+	reg, err := wsregistry.Load()
+	if err != nil {
+		onLog(fmt.Sprintf("  \u26a0 %v", err))
+		return
+	}
+	if len(reg.Workspaces) == 0 {
+		onLog("  (none)")
+		return
+	}
+	for _, e := range reg.Workspaces {
+		problems := e.Problems()
+		if len(problems) == 0 {
+			onLog(fmt.Sprintf("  \u2713 %s \u2192 %s", e.Dir, e.Environment()))
+			continue
+		}
+		onLog(fmt.Sprintf("  \u26a0 %s \u2192 %s", e.Dir, e.Environment()))
+		for _, p := range problems {
+			onLog("      " + p)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package emacs
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	sharedemacs "github.com/justme0606/rocq-bootstrap/shared/emacs"
+)
+
+// Emacs packages set up by the installer.
+const (
+	ProofGeneral = sharedemacs.ProofGeneral
+	CompanyCoq   = sharedemacs.CompanyCoq
+)
+
+// HasPackage reports whether Emacs finds the given package.
+func HasPackage(emacsBin, pkg string) bool {
+	return sharedemacs.HasPackage(emacsBin, pkg)
+}
+
+// InstallPackage installs the given package from MELPA if not already present.
+func InstallPackage(emacsBin, pkg string) error {
+	return sharedemacs.InstallPackage(emacsBin, pkg)
+}
+
+// FindEmacs searches for the Emacs executable.
+func FindEmacs() (string, error) {
+	// Try PATH first
+	path, err := exec.LookPath("emacs")
+	if err == nil {
+		return path, nil
+	}
+
+	// Try the GNU installer locations, which include the Emacs version
+	for _, pattern := range []string{
+		`C:\Program Files\Emacs\emacs-*\bin\emacs.exe`,
+		`C:\Program Files\Emacs\bin\emacs.exe`,
+	} {
+		matches, _ := filepath.Glob(pattern)
+		for i := len(matches) - 1; i >= 0; i-- {
+			if _, err := exec.LookPath(matches[i]); err == nil {
+				return matches[i], nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("Emacs not found in PATH or common locations")
+}
@@ -6,19 +6,109 @@ import (
 	"time"
 
 	sharedgui "github.com/justme0606/rocq-bootstrap/shared/gui"
+	"github.com/justme0606/rocq-bootstrap/shared/settings"
 
 	"github.com/justme0606/rocq-bootstrap/windows/internal/doctor"
 	"github.com/justme0606/rocq-bootstrap/windows/internal/installer"
 	"github.com/justme0606/rocq-bootstrap/windows/internal/manifest"
 	"github.com/justme0606/rocq-bootstrap/windows/internal/releases"
+	"github.com/justme0606/rocq-bootstrap/windows/internal/vscode"
+	"github.com/justme0606/rocq-bootstrap/windows/internal/workspace"
 )
 
 const totalSteps = 7
 
+// Options holds the command-line settings passed to the GUI.
+type Options struct {
+	ShowLog              bool   // show the log panel
+	Template             string // workspace template ID; empty means the default template
+	WorkspaceDir         string // workspace directory; empty means the remembered or default one
+	CodeWorkspace        bool   // also create a .code-workspace file
+	Editor               string // editor to configure; empty means VSCode, or Emacs if VSCode is not found
+	CodeEditor           string // VSCode-family editor to use, by CLI or label; empty means the first one found
+	VSIX                 string // local .vsix of the extension to install; empty means the manifest's or the Marketplace's
+	SettingsScope        string // scope of the language server setting (workspace.ScopeWorkspace, ScopeUser); empty means the workspace
+	Profile              string // dedicated VSCode profile; empty means the default profile
+	UninstallConflicting bool   // uninstall the conflicting VSCoq/VSRocq extension rather than disable it in the window opened by the installer
+	CompanyCoq           bool   // with Emacs, also set up company-coq
+	Build                string // build system to set up in the workspace; empty means none
+	Git                  bool   // initialize the workspace as a git repository
+}
+
 // Run creates and runs the GUI application.
-func Run(m *manifest.Manifest, templates fs.FS, icon []byte, version string, showLog bool) {
+func Run(m *manifest.Manifest, templates fs.FS, icon []byte, version string, opts *Options) {
 	currentManifest := m
 
+	workspaceDir := &sharedgui.FolderOption{Label: "Workspace:", Path: opts.WorkspaceDir}
+	if workspaceDir.Path == "" {
+		workspaceDir.Path, _ = settings.Load().Workspace()
+	}
+	codeWorkspace := &sharedgui.CheckOption{
+		Label:   "Also create a .code-workspace file",
+		Checked: opts.CodeWorkspace,
+	}
+	git := &sharedgui.CheckOption{
+		Label:   "Initialize the workspace as a git repository",
+		Checked: opts.Git,
+	}
+	// The profile is named by --profile, or else the default one.
+	profileName := opts.Profile
+	if profileName == "" {
+		profileName = vscode.DefaultProfile
+	}
+	profile := &sharedgui.CheckOption{
+		Label:   fmt.Sprintf("Use a dedicated VSCode profile (%s)", profileName),
+		Checked: opts.Profile != "",
+	}
+	uninstallConflicting := &sharedgui.CheckOption{
+		Label:   "Uninstall a conflicting VSCoq/VSRocq extension instead of disabling it for this session",
+		Checked: opts.UninstallConflicting,
+	}
+	companyCoq := &sharedgui.CheckOption{
+		Label:   "With Emacs, also set up company-coq",
+		Checked: opts.CompanyCoq,
+	}
+	editor := &sharedgui.SelectOption{Label: "Editor:", Selected: opts.Editor}
+	editor.Add("", "VSCode, or Emacs if VSCode is not found")
+	editor.Add(workspace.EditorVSCode, "VSCode")
+	editor.Add(workspace.EditorEmacs, "Emacs (Proof General)")
+	editor.Add(workspace.EditorNeovim, "Neovim (language server) / Vim (Coqtail)")
+	// The VSCode-family editor is only asked for when several are installed.
+	codeEditor := &sharedgui.SelectOption{Label: "VSCode editor:", Selected: opts.CodeEditor}
+	codeEditors := vscode.FindEditors()
+	if e, err := vscode.SelectEditor(codeEditors, opts.CodeEditor); err == nil {
+		codeEditor.Selected = e.Bin
+	}
+	for _, e := range codeEditors {
+		codeEditor.Add(e.Bin, fmt.Sprintf("%s (%s)", e.Label(), e.Bin))
+	}
+	settingsScope := &sharedgui.SelectOption{Label: "Language server setting:", Selected: opts.SettingsScope}
+	if settingsScope.Selected == "" {
+		settingsScope.Selected = workspace.ScopeWorkspace
+	}
+	settingsScope.Add(workspace.ScopeWorkspace, "Workspace (.vscode/settings.json)")
+	settingsScope.Add(workspace.ScopeUser, "VSCode user settings (all folders)")
+	build := &sharedgui.SelectOption{Label: "Build:", Selected: opts.Build}
+	build.Add("", "None")
+	build.Add(workspace.BuildMake, "Makefile (rocq makefile)")
+	build.Add(workspace.BuildDune, "dune")
+	template := &sharedgui.SelectOption{Label: "Template:", Selected: opts.Template}
+	if template.Selected == "" {
+		template.Selected = workspace.DefaultTemplate
+	}
+	// A broken template tree is reported by the installer when it creates the workspace.
+	if list, err := workspace.ListTemplates(templates); err == nil {
+		for _, t := range list {
+			template.Add(t.ID, t.Name)
+		}
+	}
+
+	options := []sharedgui.Option{workspaceDir, template, build, editor}
+	if len(codeEditors) > 1 {
+		options = append(options, codeEditor)
+	}
+	options = append(options, settingsScope, git, codeWorkspace, profile, uninstallConflicting, companyCoq)
+
 	cfg := &sharedgui.AppConfig{
 		Version:    version,
 		TotalSteps: totalSteps,
@@ -27,13 +117,13 @@ func Run(m *manifest.Manifest, templates fs.FS, icon []byte, version string, sho
 			"Verify checksum",
 			"Install application",
 			"Locate language server",
-			"Check for VSCode",
+			"Check for editor",
 			"Create workspace",
-			"Configure VSCode",
+			"Configure editor",
 		},
 		RocqVersion:     m.RocqVersion,
 		PlatformRelease: m.PlatformRelease,
-		ShowLog:         showLog,
+		ShowLog:         opts.ShowLog,
 		Icon:            icon,
 
 		FetchReleases:    releases.FetchReleases,
@@ -58,12 +148,23 @@ func Run(m *manifest.Manifest, templates fs.FS, icon []byte, version string, sho
 			return fmt.Sprintf("Install new (%s)", installer.DefaultInstallDir(currentManifest.RocqVersion, currentManifest.PlatformRelease))
 		},
 
+		Options: options,
+
 		RunDoctor: func(onLog func(string)) {
 			doctor.Run(onLog)
 		},
 
+		RelinkWorkspace: func(dir, target string) error {
+			_, err := installer.Relink(dir, target)
+			return err
+		},
+
 		RunInstall: func(ctx *sharedgui.InstallContext, existingSelection string, skipInstall bool) {
-			runInstall(ctx, currentManifest, templates, existingSelection, skipInstall)
+			profileSelected := ""
+			if profile.Checked {
+				profileSelected = profileName
+			}
+			runInstall(ctx, currentManifest, templates, template.Selected, workspaceDir.Path, build.Selected, editor.Selected, codeEditor.Selected, settingsScope.Selected, opts.VSIX, profileSelected, codeWorkspace.Checked, uninstallConflicting.Checked, git.Checked, companyCoq.Checked, existingSelection, skipInstall)
 		},
 	}
 
@@ -71,7 +172,7 @@ func Run(m *manifest.Manifest, templates fs.FS, icon []byte, version string, sho
 }
 
 func runInstall(ctx *sharedgui.InstallContext, m *manifest.Manifest, templates fs.FS,
-	existingDir string, skipInstall bool) {
+	templateID, workspaceDir, build, editor, codeEditor, settingsScope, vsix, profile string, codeWorkspace, uninstallConflicting, git, companyCoq bool, existingDir string, skipInstall bool) {
 
 	startTime := time.Now()
 
@@ -89,12 +190,24 @@ func runInstall(ctx *sharedgui.InstallContext, m *manifest.Manifest, templates f
 	}
 
 	cfg := &installer.Config{
-		Manifest:    m,
-		Templates:   templates,
-		InstallDir:  installDir,
-		SkipInstall: skipInstall,
-		Logger:      logger,
-		OnStep:      ctx.OnStep,
+		Manifest:             m,
+		Templates:            templates,
+		Template:             templateID,
+		WorkspaceDir:         workspaceDir,
+		CodeWorkspace:        codeWorkspace,
+		Editor:               editor,
+		CodeEditor:           codeEditor,
+		SettingsScope:        settingsScope,
+		VSIX:                 vsix,
+		Profile:              profile,
+		UninstallConflicting: uninstallConflicting,
+		CompanyCoq:           companyCoq,
+		Build:                build,
+		Git:                  git,
+		InstallDir:           installDir,
+		SkipInstall:          skipInstall,
+		Logger:               logger,
+		OnStep:               ctx.OnStep,
 	}
 
 	result, err := installer.Run(cfg)
@@ -103,7 +216,7 @@ func runInstall(ctx *sharedgui.InstallContext, m *manifest.Manifest, templates f
 			logger.Log("ERROR: %v", err)
 		}
 		ctx.LogPanel.Append(fmt.Sprintf("ERROR: %v", err))
-		sharedgui.ShowError(ctx.Window, ctx.InstallBtn, err.Error())
+		ctx.Abort(err.Error())
 		return
 	}
 
@@ -111,7 +224,7 @@ func runInstall(ctx *sharedgui.InstallContext, m *manifest.Manifest, templates f
 
 	elapsed := sharedgui.FormatDuration(time.Since(startTime))
 
-	if !result.VSCodeFound {
+	if !result.VSCodeFound && result.Editor == "" {
 		ctx.StatusLabel.SetText(fmt.Sprintf("Rocq Platform installed in %s — VSCode not found", elapsed))
 		ctx.LogPanel.Append(fmt.Sprintf("Rocq Platform installed successfully in %s.", elapsed))
 		ctx.LogPanel.Append("VSCode was not found. Install VSCode then re-run this installer to configure the workspace.")
@@ -127,20 +240,29 @@ func runInstall(ctx *sharedgui.InstallContext, m *manifest.Manifest, templates f
 		return
 	}
 
+	// Emacs and Neovim need a step from the user to load the workspace
+	// configuration.
+	editorNote := ""
+	if hint := workspace.SetupHint(result.Editor, result.WorkspaceDir); hint != "" {
+		editorNote = "\n\n" + hint
+		ctx.LogPanel.Append(hint)
+	}
+
 	ctx.StatusLabel.SetText(fmt.Sprintf("Installation complete! (%s)", elapsed))
 	ctx.LogPanel.Append(fmt.Sprintf("Installation complete! (%s)", elapsed))
 	ctx.LogPanel.Append(fmt.Sprintf("Install directory: %s", result.InstallDir))
-	ctx.LogPanel.Append(fmt.Sprintf("Workspace: %%USERPROFILE%%\\%s", installer.WorkspaceName))
+	ctx.LogPanel.Append(fmt.Sprintf("Workspace: %s", result.WorkspaceDir))
 
 	if ctx.Checklist != nil {
 		ctx.Checklist.AppendSummary("")
 		ctx.Checklist.AppendSummary(fmt.Sprintf("Installation complete! (%s)", elapsed))
 		ctx.Checklist.AppendSummary(fmt.Sprintf("Install directory: %s", result.InstallDir))
-		ctx.Checklist.AppendSummary(fmt.Sprintf("Workspace: %%USERPROFILE%%\\%s", installer.WorkspaceName))
+		ctx.Checklist.AppendSummary(fmt.Sprintf("Workspace: %s", result.WorkspaceDir))
 	}
 
 	sharedgui.ShowSuccess(ctx.Window,
 		fmt.Sprintf("Rocq Platform has been installed successfully in %s.\n\n", elapsed)+
 			fmt.Sprintf("Install directory: %s\n", result.InstallDir)+
-			fmt.Sprintf("Workspace: %%USERPROFILE%%\\%s", installer.WorkspaceName))
+			fmt.Sprintf("Workspace: %s", result.WorkspaceDir)+
+			editorNote)
 }
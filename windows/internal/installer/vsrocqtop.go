@@ -15,6 +15,12 @@ func FindLanguageServerTop(installDir, rocqVersion string) (string, error) {
 	if vscode.IsCoq(rocqVersion) {
 		binBase = "vscoqtop"
 	}
+	return findBinary(installDir, binBase)
+}
+
+// findBinary searches for binBase (with or without .exe) in
+// <installDir>/bin/, then recursively in installDir.
+func findBinary(installDir, binBase string) (string, error) {
 	names := []string{binBase, binBase + ".exe"}
 
 	debugLog("[%s] searching in %s", binBase, installDir)
@@ -0,0 +1,199 @@
+package installer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/justme0606/rocq-bootstrap/shared/registry"
+	"github.com/justme0606/rocq-bootstrap/windows/internal/emacs"
+	"github.com/justme0606/rocq-bootstrap/windows/internal/manifest"
+	"github.com/justme0606/rocq-bootstrap/windows/internal/vscode"
+	"github.com/justme0606/rocq-bootstrap/windows/internal/workspace"
+)
+
+// register records the workspace and the installation it is bound to in
+// the workspace registry.
+func register(m *manifest.Manifest, workspaceDir, installDir, topPath, profile, userSettings string, logger *Logger) {
+	err := registry.Register(&registry.Entry{
+		Dir:             workspaceDir,
+		PlatformRelease: m.PlatformRelease,
+		RocqVersion:     m.RocqVersion,
+		InstallDir:      installDir,
+		LanguageServer:  topPath,
+		Profile:         profile,
+		UserSettings:    userSettings,
+	})
+	if err != nil {
+		logger.Log("WARNING: could not register workspace: %v", err)
+	}
+}
+
+// setUpBuild writes the files of the build system into the workspace and
+// checks that the workspace builds with the installed binaries.
+func setUpBuild(workspaceDir, system string, coq bool, env map[string]string, logger *Logger) error {
+	if err := workspace.WriteBuildFiles(workspaceDir, system, coq); err != nil {
+		return err
+	}
+	logger.Log("Building workspace with %s", system)
+	if err := workspace.RunBuild(workspaceDir, workspace.BuildTasks(workspaceDir, coq), env); err != nil {
+		return err
+	}
+	logger.Log("Workspace build OK")
+	return nil
+}
+
+// initGit initializes the workspace as a git repository. Failures are
+// only logged: the workspace is usable without git.
+func initGit(workspaceDir string, logger *Logger) {
+	if err := workspace.InitGit(workspaceDir); err != nil {
+		logger.Log("WARNING: git repository not initialized: %v", err)
+		return
+	}
+	logger.Log("Git repository ready in %s", workspaceDir)
+}
+
+// writeProjectFiles writes the VSCode build tasks and extension
+// recommendations and, when requested, the .code-workspace file.
+func writeProjectFiles(workspaceDir, extensionID string, tasks []workspace.Task, env map[string]string, codeWorkspace bool, logger *Logger) {
+	if err := workspace.WriteVSCodeTasks(workspaceDir, tasks, env); err != nil {
+		logger.Log("WARNING: VSCode tasks not written: %v", err)
+	}
+	if err := workspace.WriteVSCodeExtensions(workspaceDir, extensionID, vscode.ConflictingExtensionID(extensionID)); err != nil {
+		logger.Log("WARNING: VSCode extension recommendations not written: %v", err)
+	}
+	if codeWorkspace {
+		if err := workspace.WriteCodeWorkspace(workspaceDir); err != nil {
+			logger.Log("WARNING: .code-workspace file not written: %v", err)
+		}
+	}
+}
+
+// writeActivation writes the activation scripts putting the binaries
+// installed next to the language server on PATH.
+func writeActivation(workspaceDir, topPath string) error {
+	return workspace.WriteActivationScripts(workspaceDir, &workspace.ActivationEnv{Path: []string{filepath.Dir(topPath)}})
+}
+
+// configureEmacs installs Proof General (and company-coq) when Emacs is
+// found, and writes the Emacs file of the workspace pointing it at the
+// binaries of env.
+func configureEmacs(workspaceDir string, env *workspace.ActivationEnv, companyCoq bool, logger *Logger) error {
+	if emacsBin, err := emacs.FindEmacs(); err != nil {
+		logger.Log("WARNING: %v, Emacs packages not installed", err)
+	} else {
+		logger.Log("Emacs: %s", emacsBin)
+		packages := []string{emacs.ProofGeneral}
+		if companyCoq {
+			packages = append(packages, emacs.CompanyCoq)
+		}
+		for _, pkg := range packages {
+			if err := emacs.InstallPackage(emacsBin, pkg); err != nil {
+				logger.Log("WARNING: %s install failed: %v", pkg, err)
+			}
+		}
+	}
+
+	if err := workspace.WriteEmacsConfig(workspaceDir, env, companyCoq); err != nil {
+		return err
+	}
+	logger.Log("Emacs configuration written, load it from your init file with %s", workspace.EmacsLoadForm(workspaceDir))
+	return nil
+}
+
+// configureVim writes the Neovim and Vim files of the workspace, pointing
+// them at the binaries of env and the language server topPath.
+func configureVim(workspaceDir string, env *workspace.ActivationEnv, topPath string, logger *Logger) error {
+	if err := workspace.WriteVimConfig(workspaceDir, env, topPath); err != nil {
+		return err
+	}
+	logger.Log("Neovim/Vim configuration written, %s", workspace.SetupHint(workspace.EditorNeovim, workspaceDir))
+	return nil
+}
+
+// taskEnv returns the environment giving the build tasks the binaries
+// installed next to the language server.
+func taskEnv(topPath string) map[string]string {
+	if topPath == "" {
+		return nil
+	}
+	return map[string]string{"PATH": filepath.Dir(topPath) + ";${env:PATH}"}
+}
+
+// Relink binds the registered workspace dir to the Rocq Platform installed
+// in installDir: it points the VSCode settings, build tasks, activation
+// scripts and Emacs, Neovim and Vim files (if any) at that installation's binaries, then
+// updates the registry.
+func Relink(dir, installDir string) (*registry.Entry, error) {
+	reg, err := registry.Load()
+	if err != nil {
+		return nil, err
+	}
+	entry := reg.Find(dir)
+	if entry == nil {
+		return nil, fmt.Errorf("%s is not a registered workspace", dir)
+	}
+	if !hasRocqInstallation(installDir) {
+		return nil, fmt.Errorf("no Rocq Platform installation in %s", installDir)
+	}
+
+	var topPath, settingsKey string
+	for _, key := range []string{"vsrocq", "vscoq"} {
+		if p, err := findBinary(installDir, key+"top"); err == nil {
+			topPath, settingsKey = p, key+".path"
+			break
+		}
+	}
+	if topPath != "" {
+		// Drop the setting of the other extension.
+		settings := map[string]interface{}{"vsrocq.path": nil, "vscoq.path": nil}
+		settings[settingsKey] = settingsPath(topPath)
+		if err := workspace.WriteScopedSettings(entry.Dir, entry.UserSettings, settings); err != nil {
+			return nil, fmt.Errorf("vscode config: %w", err)
+		}
+		tasks := workspace.BuildTasks(entry.Dir, settingsKey == "vscoq.path")
+		if err := workspace.WriteVSCodeTasks(entry.Dir, tasks, taskEnv(topPath)); err != nil {
+			return nil, fmt.Errorf("vscode tasks: %w", err)
+		}
+		if err := writeActivation(entry.Dir, topPath); err != nil {
+			return nil, fmt.Errorf("activation scripts: %w", err)
+		}
+		env := &workspace.ActivationEnv{Path: []string{filepath.Dir(topPath)}}
+		if ec, err := workspace.ReadEmacsConfig(entry.Dir); err == nil {
+			if err := workspace.WriteEmacsConfig(entry.Dir, env, ec.CompanyCoq); err != nil {
+				return nil, fmt.Errorf("emacs config: %w", err)
+			}
+		}
+		if workspace.HasVimConfig(entry.Dir) {
+			if err := workspace.WriteVimConfig(entry.Dir, env, topPath); err != nil {
+				return nil, fmt.Errorf("vim config: %w", err)
+			}
+		}
+	}
+
+	rocqShort, release := parseInstallDir(installDir)
+	relinked := &registry.Entry{
+		Dir:             entry.Dir,
+		PlatformRelease: release,
+		RocqVersion:     rocqShort,
+		InstallDir:      installDir,
+		LanguageServer:  topPath,
+		Profile:         entry.Profile,
+		UserSettings:    entry.UserSettings,
+	}
+	reg.Put(relinked)
+	if err := reg.Save(); err != nil {
+		return nil, err
+	}
+	return relinked, nil
+}
+
+// parseInstallDir returns the Rocq major.minor and release year.month
+// encoded in a directory named like DefaultInstallDir, or empty strings.
+func parseInstallDir(installDir string) (rocqShort, releaseShort string) {
+	parts := strings.Split(filepath.Base(installDir), "~")
+	if len(parts) != 3 {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
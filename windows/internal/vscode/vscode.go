@@ -1,12 +1,22 @@
 package vscode
 
 import (
-	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 
 	sharedvscode "github.com/justme0606/rocq-bootstrap/shared/vscode"
 )
 
+// Extension IDs of VSRocq and VSCoq.
+const (
+	RocqExtensionID = sharedvscode.RocqExtensionID
+	CoqExtensionID  = sharedvscode.CoqExtensionID
+)
+
+// DefaultProfile is the name of the dedicated VSCode profile for Rocq.
+const DefaultProfile = sharedvscode.DefaultProfile
+
 // IsCoq returns true if the version refers to a Coq release (major version < 9).
 func IsCoq(version string) bool {
 	return sharedvscode.IsCoq(version)
@@ -17,34 +27,92 @@ func ExtensionIDForVersion(rocqVersion string) string {
 	return sharedvscode.ExtensionIDForVersion(rocqVersion)
 }
 
-// InstallExtension installs the given VSCode extension if not already present.
-func InstallExtension(codeBin, extensionID string) error {
-	return sharedvscode.InstallExtension(codeBin, extensionID)
+// InstallExtension installs the given VSCode extension in the profile if not already present.
+func InstallExtension(codeBin, profile, extensionID string) error {
+	return sharedvscode.InstallExtension(codeBin, profile, extensionID)
 }
 
-// OpenWorkspace opens VSCode with the given workspace directory.
-func OpenWorkspace(codeBin, workspaceDir string) error {
-	return sharedvscode.OpenWorkspace(codeBin, workspaceDir)
+// InstallVSIX installs the extension packaged in the .vsix file at path in the profile.
+func InstallVSIX(codeBin, profile, path string) error {
+	return sharedvscode.InstallVSIX(codeBin, profile, path)
 }
 
-// FindCode searches for the VSCode CLI executable.
-func FindCode() (string, error) {
-	// Try PATH first
-	path, err := exec.LookPath("code")
-	if err == nil {
-		return path, nil
-	}
+// EnsureExtensionVersion installs the given version of the extension in the profile unless it is the installed one.
+func EnsureExtensionVersion(codeBin, profile, extensionID, version string) (string, error) {
+	return sharedvscode.EnsureExtensionVersion(codeBin, profile, extensionID, version)
+}
 
-	// Try common Windows install locations
-	candidates := []string{
-		`C:\Program Files\Microsoft VS Code\bin\code.cmd`,
-		`C:\Program Files (x86)\Microsoft VS Code\bin\code.cmd`,
-	}
-	for _, c := range candidates {
-		if _, err := exec.LookPath(c); err == nil {
-			return c, nil
+// LanguageServerVersion returns the version of the language server at topPath, or "" if unknown.
+func LanguageServerVersion(topPath string) string {
+	return sharedvscode.LanguageServerVersion(topPath)
+}
+
+// CompatibleVersions reports whether the extension version works with the language server version.
+func CompatibleVersions(client, server string) bool {
+	return sharedvscode.CompatibleVersions(client, server)
+}
+
+// ConflictingExtensionID returns the extension that must not be enabled together with extensionID.
+func ConflictingExtensionID(extensionID string) string {
+	return sharedvscode.ConflictingExtensionID(extensionID)
+}
+
+// InstalledExtensionVersion returns the version of the given extension installed in the profile.
+func InstalledExtensionVersion(codeBin, profile, extensionID string) (string, error) {
+	return sharedvscode.InstalledExtensionVersion(codeBin, profile, extensionID)
+}
+
+// OpenWorkspace opens VSCode with the given workspace directory in the profile, disabling the given extensions in its window.
+func OpenWorkspace(codeBin, profile, workspaceDir string, disabled ...string) error {
+	return sharedvscode.OpenWorkspace(codeBin, profile, workspaceDir, disabled...)
+}
+
+// UninstallExtension removes the given extension from the profile.
+func UninstallExtension(codeBin, profile, extensionID string) error {
+	return sharedvscode.UninstallExtension(codeBin, profile, extensionID)
+}
+
+// RemovedExtension is an extension the installer uninstalled, recorded to be restored later.
+type RemovedExtension = sharedvscode.RemovedExtension
+
+// Editor is an installed VSCode-family editor.
+type Editor = sharedvscode.Editor
+
+// SelectEditor returns the editor of editors that choice names, or the first one when choice is empty.
+func SelectEditor(editors []Editor, choice string) (*Editor, error) {
+	return sharedvscode.SelectEditor(editors, choice)
+}
+
+// installDirs maps the commands of the VSCode-family editors to the
+// directory they install into, under Program Files for a system install
+// or %LOCALAPPDATA%\Programs for a user install, and their CLI in it.
+var installDirs = map[string][2]string{
+	"code":          {"Microsoft VS Code", `bin\code.cmd`},
+	"code-insiders": {"Microsoft VS Code Insiders", `bin\code-insiders.cmd`},
+	"codium":        {"VSCodium", `bin\codium.cmd`},
+	"cursor":        {"cursor", `resources\app\bin\cursor.cmd`},
+}
+
+// FindEditors returns the installed VSCode-family editors, looking for the
+// CLI of each flavor in PATH, then in its system and user install
+// directories.
+func FindEditors() []Editor {
+	var editors []Editor
+	for _, f := range sharedvscode.Flavors {
+		var candidates []string
+		if path, err := exec.LookPath(f.Command); err == nil {
+			candidates = append(candidates, path)
+		}
+		dir := installDirs[f.Command]
+		for _, root := range []string{`C:\Program Files`, `C:\Program Files (x86)`, filepath.Join(os.Getenv("LOCALAPPDATA"), "Programs")} {
+			candidates = append(candidates, filepath.Join(root, dir[0], dir[1]))
+		}
+		for _, c := range candidates {
+			if _, err := exec.LookPath(c); err == nil {
+				editors = append(editors, Editor{Flavor: f, Bin: c})
+				break
+			}
 		}
 	}
-
-	return "", fmt.Errorf("VSCode (code) not found in PATH or common locations")
+	return editors
 }